@@ -1,11 +1,29 @@
 package database
 
+import (
+	"os"
+	"strings"
+)
+
 type Database struct {
 	PostgresDatabase PostgresDatabase
 }
 
+const (
+	DataStoreCSV      = "csv"
+	DataStorePostgres = "postgres"
+)
+
 func NewDatabase() Database {
 	return Database{
 		PostgresDatabase: NewPostgresDatabase(),
 	}
-}
\ No newline at end of file
+}
+
+// DataStore reports where flood events and prediction runs live, set with DATA_STORE and defaulting to the CSV files.
+func DataStore() string {
+	if strings.ToLower(strings.TrimSpace(os.Getenv("DATA_STORE"))) == DataStorePostgres {
+		return DataStorePostgres
+	}
+	return DataStoreCSV
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	FloodEventSourceBnpb = "bnpb"
+	FloodEventSourceNews = "news"
+)
+
+// FloodEvent is a flood report from BNPB or the news, with the file and row it was imported from.
+type FloodEvent struct {
+	ID         int64
+	Source     string
+	SourceKey  string
+	Date       time.Time
+	City       string
+	CityID     string
+	Province   string
+	Location   string
+	Occurrence string
+	Cause      string
+	Link       string
+	SourceFile string
+	SourceRow  int
+	Raw        map[string]string
+	ImportedAt time.Time
+}
+
+type FloodEventRepository interface {
+	SaveEvents(ctx context.Context, events []FloodEvent) (inserted int, err error)
+	FindEvents(ctx context.Context, source, city string, startDate, endDate time.Time) ([]FloodEvent, error)
+}
+
+type FloodEventRepositoryImpl struct {
+	pool *pgxpool.Pool
+}
+
+func NewFloodEventRepository(pool *pgxpool.Pool) FloodEventRepository {
+	return &FloodEventRepositoryImpl{
+		pool: pool,
+	}
+}
+
+// SaveEvents keeps events already stored under the same source key and reports how many were new.
+func (r *FloodEventRepositoryImpl) SaveEvents(ctx context.Context, events []FloodEvent) (inserted int, err error) {
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(`INSERT INTO flood_events
+			(source, source_key, date, city, city_id, province, location, occurrence, cause, link, source_file, source_row, raw)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (source, source_key) DO NOTHING`,
+			e.Source, e.SourceKey, e.Date, e.City, e.CityID, e.Province, e.Location, e.Occurrence, e.Cause, e.Link, e.SourceFile, e.SourceRow, e.Raw)
+	}
+
+	results := r.pool.SendBatch(ctx, batch)
+	defer results.Close()
+	for range events {
+		tag, err := results.Exec()
+		if err != nil {
+			return inserted, err
+		}
+		inserted += int(tag.RowsAffected())
+	}
+	return inserted, nil
+}
+
+// FindEvents matches the city as a case-insensitive substring, the same way the CSV files are searched.
+func (r *FloodEventRepositoryImpl) FindEvents(ctx context.Context, source, city string, startDate, endDate time.Time) ([]FloodEvent, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, source, source_key, date, city, city_id, province, location, occurrence, cause, link, source_file, source_row, raw, imported_at
+		FROM flood_events
+		WHERE source = $1 AND strpos(lower(city), lower($2)) > 0 AND date BETWEEN $3 AND $4
+		ORDER BY source_file, source_row, id`,
+		source, city, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (e FloodEvent, err error) {
+		err = row.Scan(&e.ID, &e.Source, &e.SourceKey, &e.Date, &e.City, &e.CityID, &e.Province, &e.Location, &e.Occurrence, &e.Cause, &e.Link, &e.SourceFile, &e.SourceRow, &e.Raw, &e.ImportedAt)
+		return
+	})
+}
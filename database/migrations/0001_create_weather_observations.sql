@@ -0,0 +1,14 @@
+CREATE TABLE weather_observations (
+    latitude       TEXT             NOT NULL,
+    longitude      TEXT             NOT NULL,
+    date           DATE             NOT NULL,
+    wind_speed     DOUBLE PRECISION NOT NULL,
+    rel_humidity   DOUBLE PRECISION NOT NULL,
+    precipitation  DOUBLE PRECISION NOT NULL,
+    temp_average   DOUBLE PRECISION NOT NULL,
+    temp_max       DOUBLE PRECISION NOT NULL,
+    temp_min       DOUBLE PRECISION NOT NULL,
+    source         TEXT             NOT NULL,
+    fetched_at     TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    PRIMARY KEY (latitude, longitude, date)
+);
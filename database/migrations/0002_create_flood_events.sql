@@ -0,0 +1,21 @@
+-- source_key identifies an event within its source, the Kode Identitas Bencana for BNPB and city, date and link for news.
+CREATE TABLE flood_events (
+    id           BIGSERIAL   PRIMARY KEY,
+    source       TEXT        NOT NULL CHECK (source IN ('bnpb', 'news')),
+    source_key   TEXT        NOT NULL,
+    date         DATE        NOT NULL,
+    city         TEXT        NOT NULL,
+    city_id      TEXT        NOT NULL DEFAULT '',
+    province     TEXT        NOT NULL DEFAULT '',
+    location     TEXT        NOT NULL DEFAULT '',
+    occurrence   TEXT        NOT NULL DEFAULT '',
+    cause        TEXT        NOT NULL DEFAULT '',
+    link         TEXT        NOT NULL DEFAULT '',
+    source_file  TEXT        NOT NULL DEFAULT '',
+    source_row   INTEGER     NOT NULL DEFAULT 0,
+    raw          JSONB,
+    imported_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (source, source_key)
+);
+
+CREATE INDEX flood_events_source_date_idx ON flood_events (source, date);
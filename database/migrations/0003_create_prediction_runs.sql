@@ -0,0 +1,14 @@
+CREATE TABLE prediction_runs (
+    id           BIGSERIAL   PRIMARY KEY,
+    city         TEXT        NOT NULL,
+    start_date   DATE        NOT NULL,
+    end_date     DATE        NOT NULL,
+    params       JSONB       NOT NULL,
+    status       TEXT        NOT NULL CHECK (status IN ('succeeded', 'failed', 'cancelled')),
+    error        TEXT        NOT NULL DEFAULT '',
+    result       JSONB,
+    duration_ms  BIGINT      NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX prediction_runs_created_at_idx ON prediction_runs (created_at DESC);
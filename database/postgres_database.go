@@ -16,6 +16,7 @@ type PostgresDatabase interface {
 	GetPool() *pgxpool.Pool
 	CloseSingle()
 	ClosePool()
+	Migrate()
 }
 
 type PostgresDatabaseImpl struct {
@@ -68,3 +69,14 @@ func (db *PostgresDatabaseImpl) ClosePool() {
 		db.pool.Close()
 	}
 }
+
+// Migrate brings the schema up to date using the pool, which has to be open.
+func (db *PostgresDatabaseImpl) Migrate() {
+	applied, err := migrate(context.Background(), db.pool)
+	for _, name := range applied {
+		db.log.LogAndContinue("Applied migration %s", name)
+	}
+	if err != nil {
+		db.log.LogErrAndExit(2, err, "Failed migrating postgres database")
+	}
+}
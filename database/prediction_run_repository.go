@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	PredictionRunSucceeded = "succeeded"
+	PredictionRunFailed    = "failed"
+	PredictionRunCancelled = "cancelled"
+)
+
+// PredictionRun stores the request parameters and the serialized result of one pipeline run.
+type PredictionRun struct {
+	ID         int64
+	City       string
+	StartDate  time.Time
+	EndDate    time.Time
+	Params     json.RawMessage
+	Status     string
+	Error      string
+	Result     json.RawMessage
+	DurationMs int64
+	CreatedAt  time.Time
+}
+
+type PredictionRunRepository interface {
+	CreateRun(ctx context.Context, run PredictionRun) (id int64, err error)
+	FindRun(ctx context.Context, id int64) (PredictionRun, error)
+}
+
+type PredictionRunRepositoryImpl struct {
+	pool *pgxpool.Pool
+}
+
+func NewPredictionRunRepository(pool *pgxpool.Pool) PredictionRunRepository {
+	return &PredictionRunRepositoryImpl{
+		pool: pool,
+	}
+}
+
+func (r *PredictionRunRepositoryImpl) CreateRun(ctx context.Context, run PredictionRun) (id int64, err error) {
+	err = r.pool.QueryRow(ctx, `INSERT INTO prediction_runs (city, start_date, end_date, params, status, error, result, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		run.City, run.StartDate, run.EndDate, run.Params, run.Status, run.Error, run.Result, run.DurationMs).Scan(&id)
+	return
+}
+
+func (r *PredictionRunRepositoryImpl) FindRun(ctx context.Context, id int64) (run PredictionRun, err error) {
+	err = r.pool.QueryRow(ctx, `SELECT id, city, start_date, end_date, params, status, error, result, duration_ms, created_at
+		FROM prediction_runs
+		WHERE id = $1`,
+		id).Scan(&run.ID, &run.City, &run.StartDate, &run.EndDate, &run.Params, &run.Status, &run.Error, &run.Result, &run.DurationMs, &run.CreatedAt)
+	return
+}
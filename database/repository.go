@@ -0,0 +1,17 @@
+package database
+
+import "github.com/jackc/pgx/v5/pgxpool"
+
+type Repository struct {
+	Weather       WeatherRepository
+	FloodEvent    FloodEventRepository
+	PredictionRun PredictionRunRepository
+}
+
+func NewRepository(pool *pgxpool.Pool) Repository {
+	return Repository{
+		Weather:       NewWeatherRepository(pool),
+		FloodEvent:    NewFloodEventRepository(pool),
+		PredictionRun: NewPredictionRunRepository(pool),
+	}
+}
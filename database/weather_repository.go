@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WeatherObservation struct {
+	Latitude      string
+	Longitude     string
+	Date          time.Time
+	WindSpeed     float64
+	RelHumidity   float64
+	Precipitation float64
+	TempAverage   float64
+	TempMax       float64
+	TempMin       float64
+	Source        string
+	FetchedAt     time.Time
+}
+
+type WeatherRepository interface {
+	SaveObservations(ctx context.Context, observations []WeatherObservation) error
+	FindObservations(ctx context.Context, latitude, longitude string, startDate, endDate time.Time) ([]WeatherObservation, error)
+}
+
+type WeatherRepositoryImpl struct {
+	pool *pgxpool.Pool
+}
+
+func NewWeatherRepository(pool *pgxpool.Pool) WeatherRepository {
+	return &WeatherRepositoryImpl{
+		pool: pool,
+	}
+}
+
+// SaveObservations upserts by location and date, so refetched days replace the stored values.
+func (r *WeatherRepositoryImpl) SaveObservations(ctx context.Context, observations []WeatherObservation) error {
+	batch := &pgx.Batch{}
+	for _, o := range observations {
+		batch.Queue(`INSERT INTO weather_observations
+			(latitude, longitude, date, wind_speed, rel_humidity, precipitation, temp_average, temp_max, temp_min, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (latitude, longitude, date) DO UPDATE SET
+				wind_speed = EXCLUDED.wind_speed,
+				rel_humidity = EXCLUDED.rel_humidity,
+				precipitation = EXCLUDED.precipitation,
+				temp_average = EXCLUDED.temp_average,
+				temp_max = EXCLUDED.temp_max,
+				temp_min = EXCLUDED.temp_min,
+				source = EXCLUDED.source,
+				fetched_at = now()`,
+			o.Latitude, o.Longitude, o.Date, o.WindSpeed, o.RelHumidity, o.Precipitation, o.TempAverage, o.TempMax, o.TempMin, o.Source)
+	}
+
+	return r.pool.SendBatch(ctx, batch).Close()
+}
+
+func (r *WeatherRepositoryImpl) FindObservations(ctx context.Context, latitude, longitude string, startDate, endDate time.Time) ([]WeatherObservation, error) {
+	rows, err := r.pool.Query(ctx, `SELECT latitude, longitude, date, wind_speed, rel_humidity, precipitation, temp_average, temp_max, temp_min, source, fetched_at
+		FROM weather_observations
+		WHERE latitude = $1 AND longitude = $2 AND date BETWEEN $3 AND $4
+		ORDER BY date`,
+		latitude, longitude, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (o WeatherObservation, err error) {
+		err = row.Scan(&o.Latitude, &o.Longitude, &o.Date, &o.WindSpeed, &o.RelHumidity, &o.Precipitation, &o.TempAverage, &o.TempMax, &o.TempMin, &o.Source, &o.FetchedAt)
+		return
+	})
+}
@@ -1,40 +1,257 @@
 package helper
 
-import "sync"
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
 
 type CacheHelper interface {
-	Get(key string) (value interface{})
-	Set(key string, value interface{})
+	Get(key string) (value []byte, found bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// CacheConfig bounds the cache. A zero TTL never expires, zero limits are unbounded and an empty Dir keeps it in memory only.
+type CacheConfig struct {
+	Dir        string
+	TTL        time.Duration
+	MaxBytes   int64
+	MaxEntries int
 }
 
 type CacheHelperImpl struct {
-	logger LoggerHelper
-	data   map[string]interface{}
-	mu     sync.Mutex
+	logger  LoggerHelper
+	config  CacheConfig
+	entries map[string]*list.Element
+	recency *list.List
+	size    int64
+	mu      sync.Mutex
+	// loaded restores the persisted entries on first use, so commands that never touch the cache skip reading it.
+	loaded sync.Once
+}
+
+// cacheEntry is also the on-disk record, one gob file per key.
+type cacheEntry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
 }
 
+const (
+	cacheFileExt = ".cache"
+)
+
 func NewCacheHelper(l LoggerHelper) CacheHelper {
-	return &CacheHelperImpl{
-		logger: l,
-		data:   make(map[string]interface{}),
+	config := CacheConfig{
+		Dir:        "tmp/cache",
+		TTL:        30 * 24 * time.Hour,
+		MaxBytes:   256 << 20,
+		MaxEntries: 4096,
+	}
+	if dir, set := os.LookupEnv("CACHE_DIR"); set {
+		config.Dir = dir
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_TTL")); err == nil {
+		config.TTL = ttl
+	}
+	if maxBytes, err := strconv.ParseInt(os.Getenv("CACHE_MAX_BYTES"), 10, 64); err == nil {
+		config.MaxBytes = maxBytes
 	}
+	if maxEntries, err := strconv.Atoi(os.Getenv("CACHE_MAX_ENTRIES")); err == nil {
+		config.MaxEntries = maxEntries
+	}
+
+	return NewCacheHelperWithConfig(l, config)
+}
+
+func NewCacheHelperWithConfig(l LoggerHelper, config CacheConfig) CacheHelper {
+	h := &CacheHelperImpl{
+		logger:  l,
+		config:  config,
+		entries: make(map[string]*list.Element),
+		recency: list.New(),
+	}
+	return h
 }
 
-func (h *CacheHelperImpl) Get(key string) (value interface{}) {
+func (h *CacheHelperImpl) Get(key string) (value []byte, found bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.loaded.Do(h.load)
 
-	value, found := h.data[key]
+	element, found := h.entries[key]
 	if !found {
-		h.logger.LogAndContinue("Key not found in cache, returning nil")
-		return nil
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if h.expired(entry) {
+		h.removeLocked(element)
+		return nil, false
+	}
+
+	h.recency.MoveToFront(element)
+	return entry.Value, true
+}
+
+func (h *CacheHelperImpl) Set(key string, value []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loaded.Do(h.load)
+
+	if element, found := h.entries[key]; found {
+		h.removeLocked(element)
+	}
+
+	entry := &cacheEntry{
+		Key:   key,
+		Value: value,
 	}
-	return value
+	if h.config.TTL > 0 {
+		entry.ExpiresAt = time.Now().Add(h.config.TTL)
+	}
+	if h.config.MaxBytes > 0 && int64(len(value)) > h.config.MaxBytes {
+		h.logger.LogAndContinue("Cache value for %s exceeds the size limit, not caching", key)
+		return
+	}
+
+	h.insertLocked(entry)
+	h.persist(entry)
+	h.evictLocked()
 }
 
-func (h *CacheHelperImpl) Set(key string, value interface{}) {
+func (h *CacheHelperImpl) Delete(key string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.loaded.Do(h.load)
+
+	if element, found := h.entries[key]; found {
+		h.removeLocked(element)
+	}
+}
+
+func (h *CacheHelperImpl) insertLocked(entry *cacheEntry) {
+	h.entries[entry.Key] = h.recency.PushFront(entry)
+	h.size += int64(len(entry.Value))
+}
 
-	h.data[key] = value
+func (h *CacheHelperImpl) removeLocked(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	h.recency.Remove(element)
+	delete(h.entries, entry.Key)
+	h.size -= int64(len(entry.Value))
+
+	if h.config.Dir != "" {
+		if err := os.Remove(h.path(entry.Key)); err != nil && !os.IsNotExist(err) {
+			h.logger.LogErrAndContinue(err, "Failed removing cache file for %s", entry.Key)
+		}
+	}
+}
+
+// evictLocked drops least recently used entries until the cache is within its limits.
+func (h *CacheHelperImpl) evictLocked() {
+	for h.recency.Len() > 0 {
+		overBytes := h.config.MaxBytes > 0 && h.size > h.config.MaxBytes
+		overEntries := h.config.MaxEntries > 0 && h.recency.Len() > h.config.MaxEntries
+		if !overBytes && !overEntries {
+			return
+		}
+		h.removeLocked(h.recency.Back())
+	}
+}
+
+func (h *CacheHelperImpl) expired(entry *cacheEntry) bool {
+	return !entry.ExpiresAt.IsZero() && time.Now().After(entry.ExpiresAt)
+}
+
+func (h *CacheHelperImpl) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(h.config.Dir, hex.EncodeToString(sum[:])+cacheFileExt)
+}
+
+func (h *CacheHelperImpl) persist(entry *cacheEntry) {
+	if h.config.Dir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(h.config.Dir, 0755); err != nil {
+		h.logger.LogErrAndContinue(err, "Failed creating cache directory")
+		return
+	}
+
+	path := h.path(entry.Key)
+	tempPath := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
+	file, err := os.Create(tempPath)
+	if err != nil {
+		h.logger.LogErrAndContinue(err, "Failed creating cache file for %s", entry.Key)
+		return
+	}
+
+	err = gob.NewEncoder(file).Encode(entry)
+	file.Close()
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		h.logger.LogErrAndContinue(err, "Failed writing cache file for %s", entry.Key)
+	}
+}
+
+// load restores entries persisted by a previous run, oldest first so recency roughly survives the restart.
+func (h *CacheHelperImpl) load() {
+	if h.config.Dir == "" {
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(h.config.Dir, "*"+cacheFileExt))
+	if err != nil {
+		h.logger.LogErrAndContinue(err, "Failed listing cache directory")
+		return
+	}
+
+	type loaded struct {
+		entry   *cacheEntry
+		modTime time.Time
+	}
+	var entries []loaded
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		info, _ := file.Stat()
+		var entry cacheEntry
+		err = gob.NewDecoder(file).Decode(&entry)
+		file.Close()
+
+		if err != nil || h.expired(&entry) || h.path(entry.Key) != path {
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, loaded{entry: &entry, modTime: info.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, l := range entries {
+		h.insertLocked(l.entry)
+	}
+	h.evictLocked()
+
+	// Leftover temp files come from writes interrupted by a crash.
+	tempPaths, _ := filepath.Glob(filepath.Join(h.config.Dir, "*.tmp"))
+	for _, path := range tempPaths {
+		os.Remove(path)
+	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 	"skripsi/module"
 	"time"
 
@@ -12,8 +13,12 @@ func main() {
 	// Main Code Here
 	core := module.NewCoreModule()
 	// core.Helper.LoggerHelper.LogAndContinue("Henlo %s", os.Getenv("POSTGRES_URL"))
-	core.WebModule.Init()
-	core.WebModule.Serve()
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(core.CliModule.Run(os.Args[1:]))
+	}
+	web := core.WebModule()
+	web.Init()
+	web.Serve()
 }
 
 func init() {
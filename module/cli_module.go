@@ -0,0 +1,151 @@
+package module
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"skripsi/database"
+	"skripsi/helper"
+	"skripsi/processor"
+)
+
+type CliModule interface {
+	Run(args []string) int
+}
+
+type CliModuleImpl struct {
+	logger helper.LoggerHelper
+	db     database.Database
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func NewCliModule(l helper.LoggerHelper, db database.Database) CliModule {
+	return &CliModuleImpl{
+		logger: l,
+		db:     db,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+const cliUsage = `Usage:
+  skripsi [serve]                              start the web server
+  skripsi import bnpb [-dry-run] <file>        import a BNPB disaster export
+  skripsi import news [-dry-run] <file>        import the flood news list
+  skripsi import nasa [-dry-run] -city <city> <file>
+  skripsi import nasa [-dry-run] -latitude <lat> -longitude <lon> <file>
+                                               import a NASA POWER daily CSV download
+`
+
+// Run executes a subcommand and returns the process exit code.
+func (m *CliModuleImpl) Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(m.stderr, cliUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "import":
+		return m.runImport(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(m.stdout, cliUsage)
+		return 0
+	}
+
+	fmt.Fprintf(m.stderr, "Unknown command %q\n\n%s", args[0], cliUsage)
+	return 2
+}
+
+func (m *CliModuleImpl) runImport(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(m.stderr, cliUsage)
+		return 2
+	}
+
+	dataset := args[0]
+	flags := flag.NewFlagSet("import "+dataset, flag.ContinueOnError)
+	flags.SetOutput(m.stderr)
+	dryRun := flags.Bool("dry-run", false, "validate the file without writing to the database")
+	city := flags.String("city", "", "city whose coordinates the NASA rows belong to")
+	latitude := flags.String("latitude", "", "latitude the NASA rows belong to")
+	longitude := flags.String("longitude", "", "longitude the NASA rows belong to")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(m.stderr, cliUsage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	if dataset == processor.ImportDatasetNasa && *city != "" {
+		var found bool
+		*latitude, *longitude, found = processor.CityCoordinates(*city)
+		if !found {
+			fmt.Fprintf(m.stderr, "City %q is not available\n", *city)
+			return 2
+		}
+	}
+	if dataset == processor.ImportDatasetNasa && (*latitude == "" || *longitude == "") {
+		fmt.Fprint(m.stderr, "NASA imports need -city or both -latitude and -longitude\n")
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(m.stderr, "Opening %s fails: %v\n", path, err)
+		return 1
+	}
+	defer file.Close()
+
+	var imports processor.ImportProcessor
+	if *dryRun {
+		imports = processor.NewImportProcessor(m.logger, nil, nil)
+	} else {
+		// The web server only opens the pool for DATA_STORE=postgres, imports always need it.
+		if m.db.PostgresDatabase.GetPool() == nil {
+			m.db.PostgresDatabase.OpenPool()
+			defer m.db.PostgresDatabase.ClosePool()
+		}
+		m.db.PostgresDatabase.Migrate()
+		repository := database.NewRepository(m.db.PostgresDatabase.GetPool())
+		imports = processor.NewImportProcessor(m.logger, repository.FloodEvent, repository.Weather)
+	}
+
+	ctx := context.Background()
+	var report processor.ImportReport
+	switch dataset {
+	case processor.ImportDatasetBnpb:
+		report, err = imports.ImportBnpb(ctx, file, path)
+	case processor.ImportDatasetNews:
+		report, err = imports.ImportNews(ctx, file, path)
+	case processor.ImportDatasetNasa:
+		report, err = imports.ImportNasa(ctx, file, path, *latitude, *longitude)
+	default:
+		fmt.Fprintf(m.stderr, "Unknown dataset %q, expected bnpb, news or nasa\n", dataset)
+		return 2
+	}
+
+	m.printReport(report, *dryRun)
+	if err != nil {
+		fmt.Fprintf(m.stderr, "Import fails: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (m *CliModuleImpl) printReport(report processor.ImportReport, dryRun bool) {
+	for _, rejected := range report.Rejected {
+		fmt.Fprintf(m.stdout, "%s:%d: %s\n", report.SourceFile, rejected.Row, rejected.Reason)
+	}
+
+	fmt.Fprintf(m.stdout, "%s: %d rows read, %d accepted, %d rejected (%d duplicates)\n", report.Dataset, report.Rows, report.Accepted, len(report.Rejected), report.Duplicates)
+	if dryRun {
+		fmt.Fprintln(m.stdout, "Dry run, nothing was written")
+		return
+	}
+	fmt.Fprintf(m.stdout, "%d stored, %d already present\n", report.Inserted, report.Accepted-report.Inserted)
+}
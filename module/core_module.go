@@ -8,14 +8,25 @@ import (
 type CoreModule struct {
 	Database  database.Database
 	Helper    helper.Helper
-	WebModule WebModule
+	CliModule CliModule
 }
 
 func NewCoreModule() CoreModule {
-	l := helper.NewLoggerHelper()
+	h := helper.NewHelper()
+	db := database.NewDatabase()
+	if database.DataStore() == database.DataStorePostgres {
+		db.PostgresDatabase.OpenPool()
+		db.PostgresDatabase.Migrate()
+	}
+
 	return CoreModule{
-		Database:  database.NewDatabase(),
-		Helper:    helper.NewHelper(),
-		WebModule: NewWebModule(l),
+		Database:  db,
+		Helper:    h,
+		CliModule: NewCliModule(h.LoggerHelper, db),
 	}
 }
+
+// WebModule builds the web server with its templates, cache and job workers, which the CLI commands never need.
+func (c CoreModule) WebModule() WebModule {
+	return NewWebModule(c.Helper.LoggerHelper, c.Helper.CacheHelper, c.Database)
+}
@@ -3,6 +3,7 @@ package module
 import (
 	"fmt"
 	"os"
+	"skripsi/database"
 	"skripsi/helper"
 	"skripsi/processor"
 	"skripsi/utils"
@@ -23,7 +24,7 @@ type WebModuleImpl struct {
 	Processor processor.Processor
 }
 
-func NewWebModule(l helper.LoggerHelper) WebModule {
+func NewWebModule(l helper.LoggerHelper, cache helper.CacheHelper, db database.Database) WebModule {
 	e := echo.New()
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     strings.Split(os.Getenv("CORS_ALLOW_ORIGINS"), ","),
@@ -37,7 +38,7 @@ func NewWebModule(l helper.LoggerHelper) WebModule {
 	return &WebModuleImpl{
 		e:         e,
 		logger:    l,
-		Processor: processor.NewProcessor(l),
+		Processor: processor.NewProcessor(l, cache, db),
 	}
 }
 
@@ -47,6 +48,13 @@ func (m *WebModuleImpl) Init() {
 
 	m.e.GET("/", m.Processor.WebViewProcessor.ServeIndexPage)
 	m.e.POST("/flood", m.Processor.WebProcessor.HandleFloodPredictionRequestV2)
+
+	api := m.e.Group("/api/v1")
+	api.POST("/predictions", m.Processor.ApiProcessor.HandlePredictionRequest)
+	api.POST("/jobs", m.Processor.JobProcessor.HandleSubmitJob)
+	api.GET("/jobs/:id", m.Processor.JobProcessor.HandleGetJob)
+	api.GET("/jobs/:id/events", m.Processor.JobProcessor.HandleJobEvents)
+	api.DELETE("/jobs/:id", m.Processor.JobProcessor.HandleCancelJob)
 }
 
 func (m *WebModuleImpl) Serve() {
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"net/http"
+	"skripsi/helper"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ApiProcessor interface {
+	HandlePredictionRequest(c echo.Context) error
+}
+
+type ApiProcessorImpl struct {
+	logger     helper.LoggerHelper
+	prediction PredictionProcessor
+}
+
+func NewApiProcessor(l helper.LoggerHelper, prediction PredictionProcessor) ApiProcessor {
+	return &ApiProcessorImpl{
+		logger:     l,
+		prediction: prediction,
+	}
+}
+
+func (p *ApiProcessorImpl) HandlePredictionRequest(c echo.Context) error {
+	var request PredictionRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ApiResponse{
+			Error: "Request body is not a valid prediction request",
+		})
+	}
+
+	params, err := request.Validate()
+	if err != nil {
+		return respondPredictionError(c, err)
+	}
+
+	result, err := p.prediction.PredictFlood(c.Request().Context(), params, nil)
+	if err != nil {
+		return respondPredictionError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ApiResponse{
+		Data: result,
+	})
+}
+
+func respondPredictionError(c echo.Context, err error) error {
+	statusCode := http.StatusInternalServerError
+	if predictionErr, ok := err.(*PredictionError); ok {
+		statusCode = predictionErr.StatusCode
+	}
+
+	return c.JSON(statusCode, ApiResponse{
+		Error: err.Error(),
+	})
+}
@@ -0,0 +1,745 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/optimize"
+)
+
+const (
+	BaselinePersistence   = "persistence"
+	BaselineSeasonalNaive = "seasonal_naive"
+	BaselineSes           = "ses"
+	BaselineHolt          = "holt"
+	BaselineArima         = "arima"
+
+	ArimaMethodCss = "css"
+	ArimaMethodMle = "mle"
+
+	// ArimaOwnOrder differences every variable as many times as Differencing did.
+	ArimaOwnOrder = -1
+
+	DefaultSeasonalPeriod = 365
+	DefaultArimaP         = 1
+	DefaultArimaQ         = 1
+)
+
+var baselineModels = []string{BaselinePersistence, BaselineSeasonalNaive, BaselineSes, BaselineHolt, BaselineArima}
+
+// baselineForecaster fits its parameters on a training series once, then filters a history with them.
+type baselineForecaster interface {
+	fit(train []float64) error
+	filter(history []float64) baselineFilter
+}
+
+// baselineFilter predicts the value following the days it has seen, and is carried forward one observed day at a
+// time so a test split costs one pass over the series rather than one per test day.
+type baselineFilter interface {
+	next() float64
+	observe(value float64)
+}
+
+// BaselineEval scores the univariate baselines on the same splits as VectorAutoregressionEval, in original units.
+// The parameters are fitted on the training part of every split and kept while the test days are predicted one
+// step ahead from all the days before them, so the baselines see the same history the VAR does. The filter state
+// carries over from one test day to the next.
+func (w *Weathers) BaselineEval(ctx context.Context, step, magnitude int, options BaselineOptions) (evaluation BaselineEvaluation) {
+	evaluation.Options = options
+	if magnitude*step > 100 {
+		return
+	}
+
+	origin, offset := w.Items, 0
+	if w.HasOrigin() {
+		origin, offset = w.Diff.Origin, w.Diff.Step
+	}
+	series := make([][]float64, len(varVariables))
+	for _, d := range origin {
+		for v, value := range weatherValues(d) {
+			series[v] = append(series[v], value)
+		}
+	}
+	max, min := (&Weathers{Items: origin}).GetMaxMin()
+
+	orders := make([]int, len(varVariables))
+	for v := range varVariables {
+		orders[v] = options.ArimaD
+		if orders[v] == ArimaOwnOrder {
+			orders[v] = 0
+			if w.HasOrigin() {
+				orders[v] = w.Diff.order(v)
+			}
+		}
+		evaluation.ArimaOrders = append(evaluation.ArimaOrders, ArimaOrder{
+			Variable: varVariables[v],
+			P:        options.ArimaP,
+			D:        orders[v],
+			Q:        options.ArimaQ,
+		})
+	}
+
+	for _, name := range baselineModels {
+		model := BaselineModelEvaluation{Model: name}
+		for i := 1; i <= step && model.Error == ""; i++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			testSize := len(w.Items) * magnitude * i / 100
+			trainSize := len(w.Items) - testSize
+			// The baselines never see the STL residuals, but a split the VAR skips because its training days are
+			// too short to decompose again is skipped here too, so every model is scored on the same splits.
+			if w.Diff.Transformation == TransformationStl && trainSize < 2*w.Diff.Decomposition.Options.Period {
+				continue
+			}
+
+			predictions := make([][]float64, len(varVariables))
+			for v := range varVariables {
+				forecaster := newBaselineForecaster(name, options, orders[v])
+				if err := forecaster.fit(series[v][:trainSize+offset]); err != nil {
+					model.Error = fmt.Sprintf("%s: %v", varVariables[v], err)
+					break
+				}
+				filter := forecaster.filter(series[v][:trainSize+offset])
+				for j := trainSize; j < len(w.Items)-1; j++ {
+					predictions[v] = append(predictions[v], filter.next())
+					filter.observe(series[v][j+offset])
+				}
+			}
+			if model.Error != "" {
+				break
+			}
+
+			squaredError := Weather{}
+			for n, j := 0, trainSize; j < len(w.Items)-1; n, j = n+1, j+1 {
+				predicted := make([]float64, len(varVariables))
+				for v := range varVariables {
+					predicted[v] = predictions[v][n]
+				}
+				addSquaredError(&squaredError, weatherFromSlice(predicted), origin[j+offset])
+			}
+
+			nrmse := normalizedRmse(squaredError, len(w.Items)-1-trainSize, max, min)
+			nrmse.FillString()
+			nrmse.DateStr = fmt.Sprintf("%d - %d", 100-i*magnitude, i*magnitude)
+			model.Items = append(model.Items, nrmse)
+		}
+		if model.Error != "" {
+			model.Items = nil
+		} else {
+			model.fillMean()
+		}
+		evaluation.Models = append(evaluation.Models, model)
+	}
+	return
+}
+
+// AddModel puts the NRMSE of another model, such as the VAR, next to the baselines so it is ranked with them.
+func (e *BaselineEvaluation) AddModel(name string, nrmse Weathers) {
+	model := BaselineModelEvaluation{
+		Model: name,
+		Items: nrmse.Items,
+	}
+	if len(model.Items) == 0 {
+		model.Error = "no evaluation"
+	} else {
+		model.fillMean()
+	}
+	e.Models = append(e.Models, model)
+}
+
+// Rank orders the models without errors by their mean NRMSE for every variable. The means are taken over the
+// splits all of them were evaluated on, so a model that skipped a split is still compared on the same days.
+func (e *BaselineEvaluation) Rank() {
+	var ranked []BaselineModelEvaluation
+	common := map[string]int{}
+	for _, model := range e.Models {
+		if model.Error != "" {
+			continue
+		}
+		ranked = append(ranked, model)
+		for _, item := range model.Items {
+			common[item.DateStr]++
+		}
+	}
+
+	e.Rankings = nil
+	for v, variable := range varVariables {
+		ranking := BaselineRanking{Variable: variable}
+		for _, model := range ranked {
+			shared := BaselineModelEvaluation{Model: model.Model}
+			for _, item := range model.Items {
+				if common[item.DateStr] == len(ranked) {
+					shared.Items = append(shared.Items, item)
+				}
+			}
+			shared.fillMean()
+			nrmse := weatherValues(shared.Mean)[v]
+			if math.IsNaN(nrmse) {
+				continue
+			}
+			ranking.Models = append(ranking.Models, ModelRank{
+				Model:    model.Model,
+				Nrmse:    nrmse,
+				NrmseStr: strconv.FormatFloat(nrmse, 'f', 4, 64),
+			})
+		}
+		sort.SliceStable(ranking.Models, func(i, j int) bool {
+			return ranking.Models[i].Nrmse < ranking.Models[j].Nrmse
+		})
+		for i := range ranking.Models {
+			ranking.Models[i].Rank = i + 1
+		}
+		e.Rankings = append(e.Rankings, ranking)
+	}
+}
+
+// fillMean averages the NRMSE over the splits that had test days.
+func (m *BaselineModelEvaluation) fillMean() {
+	sums := make([]float64, len(varVariables))
+	counts := make([]int, len(varVariables))
+	for _, item := range m.Items {
+		for v, value := range weatherValues(item) {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			sums[v] += value
+			counts[v]++
+		}
+	}
+	for v := range sums {
+		if counts[v] == 0 {
+			sums[v] = math.NaN()
+			continue
+		}
+		sums[v] /= float64(counts[v])
+	}
+	m.Mean = weatherFromSlice(sums)
+	m.Mean.FillString()
+	m.Mean.DateStr = "MEAN"
+}
+
+func newBaselineForecaster(name string, options BaselineOptions, order int) baselineForecaster {
+	switch name {
+	case BaselineSeasonalNaive:
+		return &seasonalNaive{period: options.SeasonalPeriod}
+	case BaselineSes:
+		return &simpleExponentialSmoothing{}
+	case BaselineHolt:
+		return &holtSmoothing{}
+	case BaselineArima:
+		return &arima{p: options.ArimaP, d: order, q: options.ArimaQ, method: options.ArimaMethod}
+	default:
+		return &persistence{}
+	}
+}
+
+// persistence predicts the last observed value.
+type persistence struct{}
+
+func (f *persistence) fit(train []float64) error {
+	if len(train) == 0 {
+		return errors.New("no training data")
+	}
+	return nil
+}
+
+func (f *persistence) filter(history []float64) baselineFilter {
+	return newLookbackFilter(history, 1)
+}
+
+// seasonalNaive predicts the value one period before.
+type seasonalNaive struct {
+	period int
+}
+
+func (f *seasonalNaive) fit(train []float64) error {
+	if len(train) < f.period {
+		return fmt.Errorf("seasonal naive needs at least %d training days", f.period)
+	}
+	return nil
+}
+
+func (f *seasonalNaive) filter(history []float64) baselineFilter {
+	return newLookbackFilter(history, f.period)
+}
+
+// lookbackFilter predicts the value lag days before the next one.
+type lookbackFilter struct {
+	history []float64
+	lag     int
+}
+
+func newLookbackFilter(history []float64, lag int) *lookbackFilter {
+	return &lookbackFilter{history: append([]float64{}, history...), lag: lag}
+}
+
+func (f *lookbackFilter) next() float64 {
+	return f.history[len(f.history)-f.lag]
+}
+
+func (f *lookbackFilter) observe(value float64) {
+	f.history = append(f.history, value)
+}
+
+// simpleExponentialSmoothing predicts the level l_t = alpha y_t + (1 - alpha) l_t-1, started at the first value,
+// with alpha minimizing the one step squared errors of the training series.
+type simpleExponentialSmoothing struct {
+	alpha float64
+}
+
+func (f *simpleExponentialSmoothing) fit(train []float64) error {
+	if len(train) < 3 {
+		return errors.New("simple exponential smoothing needs at least 3 training days")
+	}
+	x, err := minimize(func(x []float64) float64 {
+		return newSesFilter(train, logistic(x[0])).sse
+	}, []float64{0})
+	if err != nil {
+		return err
+	}
+	f.alpha = logistic(x[0])
+	return nil
+}
+
+func (f *simpleExponentialSmoothing) filter(history []float64) baselineFilter {
+	return newSesFilter(history, f.alpha)
+}
+
+// sesFilter keeps the level and the one step squared errors of the values it has seen.
+type sesFilter struct {
+	alpha, level, sse float64
+}
+
+func newSesFilter(values []float64, alpha float64) *sesFilter {
+	filter := &sesFilter{alpha: alpha, level: values[0]}
+	for _, value := range values[1:] {
+		filter.observe(value)
+	}
+	return filter
+}
+
+func (f *sesFilter) next() float64 {
+	return f.level
+}
+
+func (f *sesFilter) observe(value float64) {
+	f.sse += (value - f.level) * (value - f.level)
+	f.level = f.alpha*value + (1-f.alpha)*f.level
+}
+
+// holtSmoothing adds a trend to the level, predicting l_t + b_t with b_t = beta (l_t - l_t-1) + (1 - beta) b_t-1,
+// started at the first value and difference.
+type holtSmoothing struct {
+	alpha, beta float64
+}
+
+func (f *holtSmoothing) fit(train []float64) error {
+	if len(train) < 4 {
+		return errors.New("holt smoothing needs at least 4 training days")
+	}
+	x, err := minimize(func(x []float64) float64 {
+		return newHoltFilter(train, logistic(x[0]), logistic(x[1])).sse
+	}, []float64{0, -2})
+	if err != nil {
+		return err
+	}
+	f.alpha, f.beta = logistic(x[0]), logistic(x[1])
+	return nil
+}
+
+func (f *holtSmoothing) filter(history []float64) baselineFilter {
+	return newHoltFilter(history, f.alpha, f.beta)
+}
+
+// holtFilter keeps the level, the trend and the one step squared errors of the values it has seen. A single value
+// starts without a trend.
+type holtFilter struct {
+	alpha, beta       float64
+	level, trend, sse float64
+}
+
+func newHoltFilter(values []float64, alpha, beta float64) *holtFilter {
+	filter := &holtFilter{alpha: alpha, beta: beta, level: values[0]}
+	if len(values) > 1 {
+		filter.trend = values[1] - values[0]
+	}
+	for _, value := range values[1:] {
+		filter.observe(value)
+	}
+	return filter
+}
+
+func (f *holtFilter) next() float64 {
+	return f.level + f.trend
+}
+
+func (f *holtFilter) observe(value float64) {
+	f.sse += (value - f.next()) * (value - f.next())
+	previous := f.level
+	f.level = f.alpha*value + (1-f.alpha)*(f.level+f.trend)
+	f.trend = f.beta*(f.level-previous) + (1-f.beta)*f.trend
+}
+
+// arima differences the series d times and fits an ARMA(p, q) to it, with a mean only when d is 0. The series is
+// standardized before fitting, and the coefficients are kept stationary and invertible through the partial
+// autocorrelation parametrization of Jones (1980). css minimizes the conditional sum of squares, mle maximizes the
+// exact Gaussian likelihood from the Kalman filter starting at the css estimates.
+type arima struct {
+	p, d, q int
+	method  string
+
+	center, scale float64
+	mean          float64
+	ar, ma        []float64
+}
+
+func (f *arima) fit(train []float64) error {
+	z := differenceSeries(train, f.d)
+	if len(z) <= 2*(f.p+f.q)+10 {
+		return fmt.Errorf("arima(%d,%d,%d) needs more training days", f.p, f.d, f.q)
+	}
+
+	f.center = 0
+	if f.d == 0 {
+		f.center = getMean(z)
+	}
+	var squares float64
+	for _, value := range z {
+		squares += (value - f.center) * (value - f.center)
+	}
+	f.scale = math.Sqrt(squares / float64(len(z)))
+	if f.scale == 0 {
+		return errors.New("the series is constant")
+	}
+	standardized := f.standardize(z)
+
+	params := f.p + f.q
+	if f.d == 0 {
+		params++
+	}
+	if params == 0 {
+		return nil
+	}
+	x, err := minimize(func(x []float64) float64 {
+		mean, ar, ma := f.unpack(x)
+		return observeAll(newCssFilter(mean, ar, ma), standardized).sse
+	}, make([]float64, params))
+	if err != nil {
+		return err
+	}
+	if f.method == ArimaMethodMle {
+		if x, err = minimize(func(x []float64) float64 {
+			mean, ar, ma := f.unpack(x)
+			return observeAll(newKalmanFilter(mean, ar, ma), standardized).deviance()
+		}, x); err != nil {
+			return err
+		}
+	}
+	f.mean, f.ar, f.ma = f.unpack(x)
+	return nil
+}
+
+func (f *arima) filter(history []float64) baselineFilter {
+	filter := &arimaFilter{model: f, history: append([]float64{}, history...)}
+	if f.method == ArimaMethodMle {
+		filter.standardized = newKalmanFilter(f.mean, f.ar, f.ma)
+	} else {
+		filter.standardized = newCssFilter(f.mean, f.ar, f.ma)
+	}
+	observeAll(filter.standardized, f.standardize(differenceSeries(history, f.d)))
+	return filter
+}
+
+func (f *arima) standardize(z []float64) []float64 {
+	standardized := make([]float64, len(z))
+	for t, value := range z {
+		standardized[t] = (value - f.center) / f.scale
+	}
+	return standardized
+}
+
+// arimaFilter runs the ARMA filter of model on the standardized differences of the days seen.
+type arimaFilter struct {
+	model        *arima
+	history      []float64
+	standardized baselineFilter
+}
+
+func (f *arimaFilter) next() float64 {
+	next := f.model.center + f.model.scale*f.standardized.next()
+
+	// Undo the differencing with the binomial expansion of (1 - L)^d.
+	coefficient := 1.0
+	for i := 1; i <= f.model.d; i++ {
+		coefficient = -coefficient * float64(f.model.d-i+1) / float64(i)
+		next -= coefficient * f.history[len(f.history)-i]
+	}
+	return next
+}
+
+func (f *arimaFilter) observe(value float64) {
+	f.history = append(f.history, value)
+	if len(f.history) > f.model.d {
+		z := differenceSeries(f.history[len(f.history)-f.model.d-1:], f.model.d)[0]
+		f.standardized.observe((z - f.model.center) / f.model.scale)
+	}
+}
+
+func observeAll[F baselineFilter](filter F, values []float64) F {
+	for _, value := range values {
+		filter.observe(value)
+	}
+	return filter
+}
+
+// unpack turns the unconstrained parameters into the mean and the stationary AR and invertible MA coefficients.
+func (f *arima) unpack(x []float64) (mean float64, ar, ma []float64) {
+	if f.d == 0 {
+		mean, x = x[0], x[1:]
+	}
+	ar = constrainStationary(x[:f.p])
+	ma = constrainStationary(x[f.p : f.p+f.q])
+	for j := range ma {
+		ma[j] = -ma[j]
+	}
+	return
+}
+
+// cssFilter computes the conditional residuals e_t = z_t - mean - sum ar_i (z_t-i - mean) - sum ma_j e_t-j from
+// t = p on, with the residuals before p taken as zero, and predicts the value following z with them.
+type cssFilter struct {
+	mean      float64
+	ar, ma    []float64
+	z         []float64
+	residuals []float64
+	sse       float64
+}
+
+func newCssFilter(mean float64, ar, ma []float64) *cssFilter {
+	return &cssFilter{mean: mean, ar: ar, ma: ma}
+}
+
+func (f *cssFilter) next() float64 {
+	t := len(f.z)
+	if t < len(f.ar) {
+		return f.mean
+	}
+	fitted := f.mean
+	for i, phi := range f.ar {
+		fitted += phi * (f.z[t-1-i] - f.mean)
+	}
+	for j, theta := range f.ma {
+		if t-1-j >= len(f.ar) {
+			fitted += theta * f.residuals[t-1-j]
+		}
+	}
+	return fitted
+}
+
+func (f *cssFilter) observe(value float64) {
+	var residual float64
+	if len(f.z) >= len(f.ar) {
+		residual = value - f.next()
+		f.sse += residual * residual
+	}
+	f.z = append(f.z, value)
+	f.residuals = append(f.residuals, residual)
+}
+
+// kalmanFilter runs the Kalman filter on the state space form of Harvey (1989) with the state covariance started at
+// its stationary value. Once the covariance is not usable it only predicts the mean and its deviance is infinite.
+type kalmanFilter struct {
+	mean                float64
+	phi, disturbance    []float64
+	state               []float64
+	covariance          [][]float64
+	filtered, moved     [][]float64
+	sumLogF, sumSquares float64
+	observations        int
+	failed              bool
+}
+
+func newKalmanFilter(mean float64, ar, ma []float64) *kalmanFilter {
+	r := max(len(ar), len(ma)+1)
+	f := &kalmanFilter{
+		mean:        mean,
+		phi:         make([]float64, r),
+		disturbance: make([]float64, r),
+		state:       make([]float64, r),
+		filtered:    squareMatrix(r),
+		moved:       squareMatrix(r),
+	}
+	copy(f.phi, ar)
+	f.disturbance[0] = 1
+	copy(f.disturbance[1:], ma)
+
+	covariance, err := stationaryCovariance(f.phi, f.disturbance)
+	if err != nil {
+		f.failed = true
+	}
+	f.covariance = covariance
+	return f
+}
+
+func (f *kalmanFilter) next() float64 {
+	if f.failed {
+		return f.mean
+	}
+	return f.mean + f.state[0]
+}
+
+func (f *kalmanFilter) observe(value float64) {
+	if f.failed {
+		return
+	}
+	covariance, filtered, moved, state, phi := f.covariance, f.filtered, f.moved, f.state, f.phi
+	variance := covariance[0][0]
+	if variance <= 0 {
+		f.failed = true
+		return
+	}
+	v := value - f.mean - state[0]
+	f.sumLogF += math.Log(variance)
+	f.sumSquares += v * v / variance
+	f.observations++
+
+	// Update with the observation, then move the state and its covariance one day ahead with the transition
+	// matrix, which has phi in its first column and ones above the diagonal.
+	for i := range state {
+		state[i] += covariance[i][0] * v / variance
+		for j := range state {
+			filtered[i][j] = covariance[i][j] - covariance[i][0]*covariance[0][j]/variance
+		}
+	}
+	first := state[0]
+	for i := range state {
+		state[i] = phi[i]*first + shiftedAt(state, i)
+		for j := range state {
+			moved[i][j] = phi[i]*filtered[0][j] + shiftedRowAt(filtered, i, j)
+		}
+	}
+	for i := range state {
+		for j := range state {
+			covariance[i][j] = moved[i][0]*phi[j] + shiftedAt(moved[i], j) + f.disturbance[i]*f.disturbance[j]
+		}
+	}
+}
+
+// deviance is the concentrated deviance n log(sigma^2) + sum log F_t, sigma^2 being the mean of v_t^2 / F_t.
+func (f *kalmanFilter) deviance() float64 {
+	if f.failed {
+		return math.Inf(1)
+	}
+	n := float64(f.observations)
+	return n*math.Log(f.sumSquares/n) + f.sumLogF
+}
+
+// stationaryCovariance solves P = T P T' + R R' for the ARMA state through (I - T kron T) vec(P) = vec(R R').
+func stationaryCovariance(phi, disturbance []float64) ([][]float64, error) {
+	r := len(phi)
+	transition := mat.NewDense(r, r, nil)
+	for i := 0; i < r; i++ {
+		transition.Set(i, 0, phi[i])
+		if i+1 < r {
+			transition.Set(i, i+1, 1)
+		}
+	}
+
+	var kronecker mat.Dense
+	kronecker.Kronecker(transition, transition)
+	system := mat.NewDense(r*r, r*r, nil)
+	for i := 0; i < r*r; i++ {
+		system.Set(i, i, 1)
+	}
+	system.Sub(system, &kronecker)
+
+	noise := mat.NewVecDense(r*r, nil)
+	for i := 0; i < r; i++ {
+		for j := 0; j < r; j++ {
+			noise.SetVec(i*r+j, disturbance[i]*disturbance[j])
+		}
+	}
+
+	var solution mat.VecDense
+	if err := solution.SolveVec(system, noise); err != nil {
+		return nil, err
+	}
+	covariance := squareMatrix(r)
+	for i := range covariance {
+		for j := range covariance[i] {
+			covariance[i][j] = solution.AtVec(i*r + j)
+		}
+	}
+	return covariance, nil
+}
+
+func squareMatrix(size int) [][]float64 {
+	rows := make([][]float64, size)
+	for i := range rows {
+		rows[i] = make([]float64, size)
+	}
+	return rows
+}
+
+// shiftedAt is values[i+1], or zero past the end.
+func shiftedAt(values []float64, i int) float64 {
+	if i+1 < len(values) {
+		return values[i+1]
+	}
+	return 0
+}
+
+// shiftedRowAt is rows[i+1][j], or zero past the last row.
+func shiftedRowAt(rows [][]float64, i, j int) float64 {
+	if i+1 < len(rows) {
+		return rows[i+1][j]
+	}
+	return 0
+}
+
+// constrainStationary maps unconstrained values to the coefficients of a stationary AR polynomial, turning them into
+// partial autocorrelations in (-1, 1) and running the Durbin-Levinson recursion.
+func constrainStationary(x []float64) []float64 {
+	coefficients := make([]float64, 0, len(x))
+	for k, value := range x {
+		partial := math.Tanh(value)
+		next := make([]float64, k+1)
+		for j := 0; j < k; j++ {
+			next[j] = coefficients[j] - partial*coefficients[k-1-j]
+		}
+		next[k] = partial
+		coefficients = next
+	}
+	return coefficients
+}
+
+// differenceSeries differences the values d times.
+func differenceSeries(values []float64, d int) []float64 {
+	for i := 0; i < d; i++ {
+		values = difference(values)
+	}
+	return values
+}
+
+func minimize(f func(x []float64) float64, initial []float64) ([]float64, error) {
+	result, err := optimize.Minimize(optimize.Problem{Func: f}, initial, &optimize.Settings{MajorIterations: 2000}, &optimize.NelderMead{})
+	if err != nil {
+		return nil, err
+	}
+	return result.X, nil
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func (o ArimaOrder) String() string {
+	return fmt.Sprintf("ARIMA(%d,%d,%d)", o.P, o.D, o.Q)
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// scoreMetrics adds the measures that need the flood probabilities rather than the counts at one threshold: the
+// Brier score and the ROC and precision-recall curves with the areas under them.
+func (n *ConfusionMatrix) scoreMetrics(scores []knnScore) {
+	n.Brier = brierScore(scores)
+	n.BrierStr = strconv.FormatFloat(n.Brier, 'f', 4, 64)
+	n.RocAucStr, n.PrAucStr = "-", "-"
+
+	roc, precisionRecall, err := scoreCurves(scores)
+	if err != nil {
+		n.CurveError = err.Error()
+		return
+	}
+	n.Roc, n.PrecisionRecall = roc, precisionRecall
+	n.RocAuc = rocAuc(roc)
+	n.PrAuc = averagePrecision(precisionRecall)
+	n.RocAucStr = strconv.FormatFloat(n.RocAuc, 'f', 4, 64)
+	n.PrAucStr = strconv.FormatFloat(n.PrAuc, 'f', 4, 64)
+}
+
+// scoreCurves sweeps the decision threshold down through every distinct probability like scikit-learn's roc_curve
+// and precision_recall_curve, a day being a predicted flood when its probability is at least the threshold. The
+// ROC curve starts at (0, 0) and the precision-recall curve ends at recall 0 with precision 1.
+func scoreCurves(scores []knnScore) (roc []RocPoint, precisionRecall []PrecisionRecallPoint, err error) {
+	var positives, negatives int
+	for _, score := range scores {
+		if score.flood {
+			positives++
+		} else {
+			negatives++
+		}
+	}
+	if positives == 0 || negatives == 0 {
+		return nil, nil, errors.New("the curves need both flood and no flood days")
+	}
+
+	sorted := make([]knnScore, len(scores))
+	copy(sorted, scores)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].probability > sorted[j].probability
+	})
+
+	roc = append(roc, RocPoint{})
+	var truePositives, falsePositives int
+	for i, score := range sorted {
+		if score.flood {
+			truePositives++
+		} else {
+			falsePositives++
+		}
+		if i+1 < len(sorted) && sorted[i+1].probability == score.probability {
+			continue
+		}
+		roc = append(roc, RocPoint{
+			FalsePositiveRate: float64(falsePositives) / float64(negatives),
+			TruePositiveRate:  float64(truePositives) / float64(positives),
+		})
+		precisionRecall = append(precisionRecall, PrecisionRecallPoint{
+			Recall:    float64(truePositives) / float64(positives),
+			Precision: float64(truePositives) / float64(truePositives+falsePositives),
+		})
+	}
+	precisionRecall = append(precisionRecall, PrecisionRecallPoint{Precision: 1})
+	return
+}
+
+// rocAuc integrates the ROC curve with the trapezoidal rule, so tied probabilities count as half right.
+func rocAuc(roc []RocPoint) (area float64) {
+	for i := 1; i < len(roc); i++ {
+		area += (roc[i].FalsePositiveRate - roc[i-1].FalsePositiveRate) * (roc[i].TruePositiveRate + roc[i-1].TruePositiveRate) / 2
+	}
+	return
+}
+
+// averagePrecision is the PR-AUC as scikit-learn's average_precision_score, the precision at every threshold
+// weighted by the recall it adds, which does not interpolate optimistically between points like the trapezoid.
+func averagePrecision(precisionRecall []PrecisionRecallPoint) (area float64) {
+	var recall float64
+	for _, point := range precisionRecall {
+		if point.Recall > recall {
+			area += (point.Recall - recall) * point.Precision
+			recall = point.Recall
+		}
+	}
+	return
+}
+
+// brierScore is the mean squared difference between the flood probability and the flood outcome.
+func brierScore(scores []knnScore) (brier float64) {
+	if len(scores) == 0 {
+		return
+	}
+	for _, score := range scores {
+		brier += (score.probability - indicator(score.flood)) * (score.probability - indicator(score.flood))
+	}
+	return brier / float64(len(scores))
+}
+
+// matthewsCorrelation is 0 when a row or column of the matrix is empty, as in scikit-learn.
+func (n *ConfusionMatrix) matthewsCorrelation() float64 {
+	tp, tn, fp, fn := float64(n.TruePositive), float64(n.TrueNegative), float64(n.FalsePositive), float64(n.FalseNegative)
+	denominator := math.Sqrt((tp + fp) * (tp + fn) * (tn + fp) * (tn + fn))
+	if denominator == 0 {
+		return 0
+	}
+	return (tp*tn - fp*fn) / denominator
+}
+
+// balancedAccuracy averages the recall of both classes, over the one present when the other has no days.
+func (n *ConfusionMatrix) balancedAccuracy() float64 {
+	var recalls []float64
+	if positives := n.TruePositive + n.FalseNegative; positives > 0 {
+		recalls = append(recalls, float64(n.TruePositive)/float64(positives))
+	}
+	if negatives := n.TrueNegative + n.FalsePositive; negatives > 0 {
+		recalls = append(recalls, float64(n.TrueNegative)/float64(negatives))
+	}
+	if len(recalls) == 0 {
+		return 0
+	}
+	return getMean(recalls)
+}
+
+// cohenKappa is the agreement of prediction and outcome beyond what their marginals give by chance, 0 when chance
+// already explains all of it.
+func (n *ConfusionMatrix) cohenKappa() float64 {
+	total := float64(n.TruePositive + n.TrueNegative + n.FalsePositive + n.FalseNegative)
+	if total == 0 {
+		return 0
+	}
+	observed := float64(n.TruePositive+n.TrueNegative) / total
+	expected := (float64(n.TruePositive+n.FalsePositive)*float64(n.TruePositive+n.FalseNegative) +
+		float64(n.TrueNegative+n.FalseNegative)*float64(n.TrueNegative+n.FalsePositive)) / (total * total)
+	if expected == 1 {
+		return 0
+	}
+	return (observed - expected) / (1 - expected)
+}
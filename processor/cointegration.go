@@ -0,0 +1,461 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	ModelVar  = "var"
+	ModelVecm = "vecm"
+
+	JohansenNoDeterministic = -1
+	JohansenConstant        = 0
+	JohansenLinearTrend     = 1
+)
+
+// johansenTraceCriticalValues[detOrder+1][n-1] holds the 90%, 95% and 99% critical values of the trace test with
+// n common trends left, the MacKinnon-Haug-Michelis (1999) values statsmodels tabulates. Six rows cover the weather variables.
+var johansenTraceCriticalValues = [3][6][3]float64{
+	{
+		{2.9762, 4.1296, 6.9406},
+		{10.4741, 12.3212, 16.3640},
+		{21.7781, 24.2761, 29.5147},
+		{37.0339, 40.1749, 46.5716},
+		{56.2839, 60.0627, 67.6367},
+		{79.5329, 83.9383, 92.7136},
+	},
+	{
+		{2.7055, 3.8415, 6.6349},
+		{13.4294, 15.4943, 19.9349},
+		{27.0669, 29.7961, 35.4628},
+		{44.4929, 47.8545, 54.6815},
+		{65.8202, 69.8189, 77.8202},
+		{91.1090, 95.7542, 104.9637},
+	},
+	{
+		{2.7055, 3.8415, 6.6349},
+		{16.1619, 18.3985, 23.1485},
+		{32.0645, 35.0116, 41.0815},
+		{51.6492, 55.2459, 62.5202},
+		{75.1027, 79.3422, 87.7748},
+		{102.4674, 107.3429, 116.9829},
+	},
+}
+
+// johansenMaxEigenCriticalValues is laid out like johansenTraceCriticalValues.
+var johansenMaxEigenCriticalValues = [3][6][3]float64{
+	{
+		{2.9762, 4.1296, 6.9406},
+		{9.4748, 11.2246, 15.0923},
+		{15.7175, 17.7961, 22.2519},
+		{21.8370, 24.1592, 29.0609},
+		{27.9160, 30.4428, 35.7359},
+		{33.9271, 36.6301, 42.2333},
+	},
+	{
+		{2.7055, 3.8415, 6.6349},
+		{12.2971, 14.2639, 18.5200},
+		{18.8928, 21.1314, 25.8650},
+		{25.1236, 27.5858, 32.7172},
+		{31.2379, 33.8777, 39.3693},
+		{37.2786, 40.0763, 45.8662},
+	},
+	{
+		{2.7055, 3.8415, 6.6349},
+		{15.0006, 17.1481, 21.7465},
+		{21.8731, 24.2522, 29.2631},
+		{28.2398, 30.8151, 36.1930},
+		{34.4202, 37.1646, 42.8612},
+		{40.5244, 43.2421, 48.9630},
+	},
+}
+
+// JohansenTest runs the trace and maximum eigenvalue tests on the levels of the six weather variables with lagDiff
+// lagged differences. detOrder -1 has no deterministic terms, 0 a constant and 1 a linear trend, as in statsmodels'
+// coint_johansen. Each rank is the first r whose test does not reject a rank of at most r at 5%.
+func (w *Weathers) JohansenTest(detOrder, lagDiff int) (result Cointegration, err error) {
+	result = Cointegration{
+		DetOrder:  detOrder,
+		LagDiff:   lagDiff,
+		Variables: varVariables,
+	}
+
+	eigenvalues, _, nobs, err := johansen(w.varMatrix(), detOrder, lagDiff)
+	if err != nil {
+		return result, err
+	}
+
+	k := len(eigenvalues)
+	result.Nobs = nobs
+	result.Eigenvalues = eigenvalues
+	result.TraceRank, result.MaxEigenRank = k, k
+	for r := 0; r < k; r++ {
+		rank := CointegrationRank{
+			Rank:                   r,
+			Eigenvalue:             eigenvalues[r],
+			MaxEigen:               -float64(nobs) * math.Log(1-eigenvalues[r]),
+			TraceCriticalValues:    johansenCriticalValues(johansenTraceCriticalValues, detOrder, k-r),
+			MaxEigenCriticalValues: johansenCriticalValues(johansenMaxEigenCriticalValues, detOrder, k-r),
+		}
+		for _, eigenvalue := range eigenvalues[r:] {
+			rank.Trace -= float64(nobs) * math.Log(1-eigenvalue)
+		}
+		rank.TraceRejected = rank.Trace > rank.TraceCriticalValues.FivePercent
+		rank.MaxEigenRejected = rank.MaxEigen > rank.MaxEigenCriticalValues.FivePercent
+		if !rank.TraceRejected && result.TraceRank == k {
+			result.TraceRank = r
+		}
+		if !rank.MaxEigenRejected && result.MaxEigenRank == k {
+			result.MaxEigenRank = r
+		}
+
+		rank.FillString()
+		result.Ranks = append(result.Ranks, rank)
+	}
+	return
+}
+
+// FitVecm fits dy_t = D_t + alpha beta' y_{t-1} + Gamma_1 dy_{t-1} + ... + Gamma_lagDiff dy_{t-lagDiff} + u_t with the
+// deterministic terms D_t of detOrder, the same the Johansen test chose the rank with: none for -1, an unrestricted
+// constant for 0 and a constant with a linear trend for 1. beta holds the first rank Johansen vectors, normalized so
+// its top block is the identity, and alpha, Gamma and D_t are then estimated by least squares.
+func (w *Weathers) FitVecm(detOrder, rank, lagDiff int) (model VecmModel, err error) {
+	data := w.varMatrix()
+	_, vectors, _, err := johansen(data, detOrder, lagDiff)
+	if err != nil {
+		return model, err
+	}
+
+	k := len(varVariables)
+	if rank < 1 || rank > k {
+		return model, fmt.Errorf("cointegration rank %d is not valid for %d variables", rank, k)
+	}
+
+	beta := mat.DenseCopyOf(vectors.Slice(0, k, 0, rank))
+	var topInverse, normalized mat.Dense
+	if err := topInverse.Inverse(beta.Slice(0, rank, 0, rank)); err != nil {
+		return model, fmt.Errorf("cointegrating vectors cannot be normalized, their top %d rows are singular: %w", rank, err)
+	}
+	normalized.Mul(beta, &topInverse)
+	beta = &normalized
+
+	// The constant and then the trend, indexed by the row of data, lead the regressors.
+	deterministic := detOrder + 1
+	nobs := len(data) - 1 - lagDiff
+	regressors := deterministic + rank + k*lagDiff
+	if nobs <= regressors {
+		return model, ErrSingularDesignMatrix
+	}
+
+	response := mat.NewDense(nobs, k, nil)
+	design := mat.NewDense(nobs, regressors, nil)
+	for i := 0; i < nobs; i++ {
+		t := lagDiff + 1 + i
+		if deterministic > 0 {
+			design.Set(i, 0, 1)
+		}
+		if deterministic > 1 {
+			design.Set(i, 1, float64(t))
+		}
+		for v := 0; v < k; v++ {
+			response.Set(i, v, data[t][v]-data[t-1][v])
+			for r := 0; r < rank; r++ {
+				design.Set(i, deterministic+r, design.At(i, deterministic+r)+beta.At(v, r)*data[t-1][v])
+			}
+			for lag := 1; lag <= lagDiff; lag++ {
+				design.Set(i, deterministic+rank+(lag-1)*k+v, data[t-lag][v]-data[t-lag-1][v])
+			}
+		}
+	}
+
+	coef, _, err := leastSquares(design, response)
+	if err != nil {
+		return model, err
+	}
+
+	var residuals, sse mat.Dense
+	residuals.Mul(design, coef)
+	residuals.Sub(response, &residuals)
+	sse.Mul(residuals.T(), &residuals)
+
+	model = VecmModel{
+		DetOrder:  detOrder,
+		Rank:      rank,
+		LagDiff:   lagDiff,
+		Variables: varVariables,
+		Nobs:      nobs,
+		Constant:  make([]float64, k),
+		Alpha:     make([][]float64, k),
+		Beta:      make([][]float64, k),
+		Gamma:     make([][]float64, k),
+		SigmaU:    make([][]float64, k),
+	}
+	if deterministic > 1 {
+		model.Trend = make([]float64, k)
+	}
+	dfResid := float64(nobs - regressors)
+	for i := 0; i < k; i++ {
+		if deterministic > 0 {
+			model.Constant[i] = coef.At(0, i)
+		}
+		if deterministic > 1 {
+			model.Trend[i] = coef.At(1, i)
+		}
+		model.Alpha[i] = make([]float64, rank)
+		model.Beta[i] = make([]float64, rank)
+		for r := 0; r < rank; r++ {
+			model.Alpha[i][r] = coef.At(deterministic+r, i)
+			model.Beta[i][r] = beta.At(i, r)
+		}
+		model.Gamma[i] = make([]float64, k*lagDiff)
+		for j := range model.Gamma[i] {
+			model.Gamma[i][j] = coef.At(deterministic+rank+j, i)
+		}
+		model.SigmaU[i] = make([]float64, k)
+		for j := 0; j < k; j++ {
+			model.SigmaU[i][j] = sse.At(i, j) / dfResid
+		}
+	}
+	return
+}
+
+// VarRepresentation rewrites the VECM as the VAR in levels of order LagDiff+1, with A_1 = I + alpha beta' + Gamma_1,
+// A_i = Gamma_i - Gamma_{i-1} and A_{LagDiff+1} = -Gamma_LagDiff, so the VAR forecasts and their MSE apply unchanged.
+// The trend stays indexed by the row of the data the VECM was fitted on.
+func (m *VecmModel) VarRepresentation() VarModel {
+	k := len(m.Constant)
+	lagOrder := m.LagDiff + 1
+	gamma := func(lag, i, v int) float64 {
+		if lag < 1 || lag > m.LagDiff {
+			return 0
+		}
+		return m.Gamma[i][(lag-1)*k+v]
+	}
+
+	model := VarModel{
+		LagOrder:     lagOrder,
+		Variables:    m.Variables,
+		Nobs:         m.Nobs,
+		Coefficients: make([][]float64, k),
+		Trend:        m.Trend,
+		SigmaU:       m.SigmaU,
+	}
+	for i := 0; i < k; i++ {
+		model.Coefficients[i] = make([]float64, 1+k*lagOrder)
+		model.Coefficients[i][0] = m.Constant[i]
+		for lag := 1; lag <= lagOrder; lag++ {
+			for v := 0; v < k; v++ {
+				coefficient := gamma(lag, i, v) - gamma(lag-1, i, v)
+				if lag == 1 {
+					for r := 0; r < m.Rank; r++ {
+						coefficient += m.Alpha[i][r] * m.Beta[v][r]
+					}
+					if i == v {
+						coefficient++
+					}
+				}
+				model.Coefficients[i][1+(lag-1)*k+v] = coefficient
+			}
+		}
+	}
+	return model
+}
+
+// VecmPrediction forecasts the day after the last item, in the units of the levels the model was fitted on.
+func (w *Weathers) VecmPrediction(model VecmModel) Weather {
+	levelModel := model.VarRepresentation()
+	return weatherFromSlice(levelModel.Forecast(w.varMatrix()))
+}
+
+// VecmForecastHorizon is ForecastHorizon for a VECM fitted on these levels. The VECM models the original units
+// directly, so only the original days are filled.
+func (w *Weathers) VecmForecastHorizon(model VecmModel, steps int, confidenceLevel float64, kValue int, knn KnnOptions) (forecast Forecast) {
+	forecast = w.ForecastHorizon(model.VarRepresentation(), steps, confidenceLevel, kValue, knn)
+	forecast.OriginalDays, forecast.Days = forecast.Days, nil
+	return
+}
+
+// johansen solves the reduced rank regression of the differences on the lagged levels, both corrected for the lagged
+// differences and the deterministic terms. The eigenvalues come in decreasing order with the cointegrating vectors in
+// the matching columns of vectors, normalized so that vectors' S11 vectors is the identity.
+func johansen(data [][]float64, detOrder, lagDiff int) (eigenvalues []float64, vectors *mat.Dense, nobs int, err error) {
+	if detOrder < JohansenNoDeterministic || detOrder > JohansenLinearTrend {
+		return nil, nil, 0, fmt.Errorf("deterministic order %d is not valid", detOrder)
+	}
+	if lagDiff < 0 || len(data) == 0 {
+		return nil, nil, 0, fmt.Errorf("lagged differences %d are not valid for %d observations", lagDiff, len(data))
+	}
+
+	levels, err := detrend(data, detOrder)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	k := len(data[0])
+	nobs = len(levels) - 1 - lagDiff
+	shortRun := k * lagDiff
+	if detOrder > JohansenNoDeterministic {
+		shortRun++
+	}
+	if nobs <= shortRun+k {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+
+	differences := mat.NewDense(nobs, k, nil)
+	lagged := mat.NewDense(nobs, k, nil)
+	var z *mat.Dense
+	if shortRun > 0 {
+		z = mat.NewDense(nobs, shortRun, nil)
+	}
+	for i := 0; i < nobs; i++ {
+		t := lagDiff + 1 + i
+		for v := 0; v < k; v++ {
+			differences.Set(i, v, levels[t][v]-levels[t-1][v])
+			lagged.Set(i, v, levels[t-1][v])
+			for lag := 1; lag <= lagDiff; lag++ {
+				z.Set(i, (lag-1)*k+v, levels[t-lag][v]-levels[t-lag-1][v])
+			}
+		}
+		if detOrder > JohansenNoDeterministic {
+			z.Set(i, shortRun-1, 1)
+		}
+	}
+
+	r0, err := partialOut(z, differences)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	r1, err := partialOut(z, lagged)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	moment := func(a, b *mat.Dense) *mat.Dense {
+		var s mat.Dense
+		s.Mul(a.T(), b)
+		s.Scale(1/float64(nobs), &s)
+		return &s
+	}
+	s00, s01, s11 := moment(r0, r0), moment(r0, r1), moment(r1, r1)
+
+	var chol00, chol11 mat.Cholesky
+	if ok := chol00.Factorize(symmetric(s00)); !ok {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+	if ok := chol11.Factorize(symmetric(s11)); !ok {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+
+	// S10 S00^-1 S01 turned symmetric with the Cholesky factor L of S11: L^-1 S10 S00^-1 S01 L^-T.
+	var s00Inv01, sig mat.Dense
+	if err := chol00.SolveTo(&s00Inv01, s01); err != nil {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+	sig.Mul(s01.T(), &s00Inv01)
+
+	var l, u mat.TriDense
+	chol11.LTo(&l)
+	chol11.UTo(&u)
+	var left, whitened mat.Dense
+	if err := left.Solve(&l, &sig); err != nil {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+	if err := whitened.Solve(&l, left.T()); err != nil {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(symmetric(&whitened), true); !ok {
+		return nil, nil, 0, fmt.Errorf("eigen decomposition of the Johansen moment matrix failed")
+	}
+	var eigenvectors, betas mat.Dense
+	eigen.VectorsTo(&eigenvectors)
+	if err := betas.Solve(&u, &eigenvectors); err != nil {
+		return nil, nil, 0, ErrSingularDesignMatrix
+	}
+
+	ascending := eigen.Values(nil)
+	vectors = mat.NewDense(k, k, nil)
+	for i := 0; i < k; i++ {
+		j := k - 1 - i
+		eigenvalues = append(eigenvalues, math.Min(math.Max(ascending[j], 0), 1-1e-12))
+		vectors.SetCol(i, mat.Col(nil, j, &betas))
+	}
+	return
+}
+
+// detrend removes a polynomial time trend of the given order from every column, order -1 leaves the data as is.
+func detrend(data [][]float64, order int) ([][]float64, error) {
+	detrended := make([][]float64, len(data))
+	for t := range data {
+		detrended[t] = append([]float64{}, data[t]...)
+	}
+	if order < 0 {
+		return detrended, nil
+	}
+
+	design := mat.NewDense(len(data), order+1, nil)
+	for t := range data {
+		for p := 0; p <= order; p++ {
+			design.Set(t, p, math.Pow(float64(t), float64(p)))
+		}
+	}
+	residuals, err := partialOut(design, mat.NewDense(len(data), len(data[0]), flatten(data)))
+	if err != nil {
+		return nil, err
+	}
+	for t := range detrended {
+		detrended[t] = mat.Row(nil, t, residuals)
+	}
+	return detrended, nil
+}
+
+// partialOut returns the residuals of regressing every column of y on x, or y itself without regressors.
+func partialOut(x, y *mat.Dense) (*mat.Dense, error) {
+	if x == nil {
+		return y, nil
+	}
+	coef, _, err := leastSquares(x, y)
+	if err != nil {
+		return nil, err
+	}
+	var residuals mat.Dense
+	residuals.Mul(x, coef)
+	residuals.Sub(y, &residuals)
+	return &residuals, nil
+}
+
+func symmetric(m mat.Matrix) *mat.SymDense {
+	n, _ := m.Dims()
+	sym := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			sym.SetSym(i, j, (m.At(i, j)+m.At(j, i))/2)
+		}
+	}
+	return sym
+}
+
+func johansenCriticalValues(table [3][6][3]float64, detOrder, commonTrends int) (critical CriticalValues) {
+	if detOrder < JohansenNoDeterministic || detOrder > JohansenLinearTrend || commonTrends < 1 || commonTrends > len(table[0]) {
+		return
+	}
+	values := table[detOrder+1][commonTrends-1]
+	critical = CriticalValues{
+		TenPercent:  values[0],
+		FivePercent: values[1],
+		OnePercent:  values[2],
+	}
+	critical.FillString()
+	return
+}
+
+func (r *CointegrationRank) FillString() {
+	r.EigenvalueStr = strconv.FormatFloat(r.Eigenvalue, 'f', 4, 64)
+	r.TraceStr = strconv.FormatFloat(r.Trace, 'f', 4, 64)
+	r.MaxEigenStr = strconv.FormatFloat(r.MaxEigen, 'f', 4, 64)
+}
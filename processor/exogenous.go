@@ -0,0 +1,222 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// ModelVarx names the VAR with exogenous regressors in the evaluation rankings.
+	ModelVarx = "varx"
+
+	ExogenousMonth   = "month"
+	ExogenousMonsoon = "monsoon"
+	ExogenousFourier = "fourier"
+	ExogenousRain3   = "rain3"
+	ExogenousRain7   = "rain7"
+
+	DefaultFourierOrder = 2
+)
+
+// ErrRedundantRainfallSum is returned when a rainfall sum of the prior days is a combination of precipitation lags the
+// model already has, which happens when precipitation is kept in levels and the window is within the lag order.
+var ErrRedundantRainfallSum = errors.New("rainfall sum is already covered by the precipitation lags")
+
+// monsoonMonths is the Jakarta wet season, November to March.
+var monsoonMonths = map[time.Month]bool{
+	time.November: true,
+	time.December: true,
+	time.January:  true,
+	time.February: true,
+	time.March:    true,
+}
+
+// FitVarx fits the VAR with the exogenous regressors of options entering on the day of the response.
+func (w *Weathers) FitVarx(lagOrder int, options ExogenousOptions) (model VarModel, err error) {
+	if err := w.checkRainfallWindows(lagOrder, options); err != nil {
+		return model, err
+	}
+
+	rows := w.ExogenousRegressors(options)
+	model, err = fitVarModel(w.varMatrix(), rows[:len(w.Items)], lagOrder, 0)
+	if err != nil {
+		return model, err
+	}
+	model.Exogenous = options
+	model.ExogenousNames = exogenousNames(options)
+	return
+}
+
+// checkRainfallWindows rejects a rainfall sum the precipitation lags of the model already cover.
+func (w *Weathers) checkRainfallWindows(lagOrder int, options ExogenousOptions) error {
+	if w.Diff.Transformation != TransformationStl && w.Diff.order(slices.Index(varVariables, "PRECTOTCORR")) == 0 {
+		for _, window := range rainfallWindows(options) {
+			if window <= lagOrder {
+				return ErrRedundantRainfallSum
+			}
+		}
+	}
+	return nil
+}
+
+// Varx is VectorAutoregression with exogenous regressors.
+func (w *Weathers) Varx(lagOrder int, options ExogenousOptions) (prediction Weather) {
+	model, err := w.FitVarx(lagOrder, options)
+	if err != nil {
+		w.Err = err
+		fmt.Printf("[VARX] error fitting model: %v", err)
+		return
+	}
+	return w.Predict(model)
+}
+
+// Predict forecasts the day after the last item, with the model's exogenous regressors when it has them.
+func (w *Weathers) Predict(model VarModel) (prediction Weather) {
+	return weatherFromSlice(w.forecastPath(model, 1)[0])
+}
+
+// ForecastExogenous predicts the row following history, exogenous being the regressors of that row.
+func (m *VarModel) ForecastExogenous(history [][]float64, exogenous []float64) []float64 {
+	forecast := m.Forecast(history)
+	offset := 1 + len(forecast)*m.LagOrder
+	for i, coef := range m.Coefficients {
+		for j, value := range exogenous {
+			forecast[i] += coef[offset+j] * value
+		}
+	}
+	return forecast
+}
+
+// forecastPath iterates the model steps days past the last item. Rainfall sums ahead of the data are taken from the
+// forecasted precipitation, integrated back into original units for a differenced series.
+func (w *Weathers) forecastPath(model VarModel, steps int) (forecasts [][]float64) {
+	if len(model.Exogenous.Regressors) == 0 {
+		return model.ForecastHorizon(w.varMatrix(), steps)
+	}
+
+	history := w.varMatrix()
+	precipitation := w.originalPrecipitation()
+	lastDate := w.Items[len(w.Items)-1].Date
+	var predictions []Weather
+	for h := 0; h < steps; h++ {
+		date := lastDate.AddDate(0, 0, h+1)
+		next := model.ForecastExogenous(history, exogenousRow(model.Exogenous, date, precipitation))
+		forecasts = append(forecasts, next)
+		history = append(history, next)
+
+		prediction := weatherFromSlice(next)
+		prediction.Date = date
+		predictions = append(predictions, prediction)
+		if w.HasOrigin() {
+			prediction = w.Integrate(predictions, len(w.Items)-1)[h]
+		}
+		precipitation = append(precipitation, prediction.Precipitation)
+	}
+	return
+}
+
+// ExogenousRegressors returns the regressors of every item followed by those of the day after the last item.
+func (w *Weathers) ExogenousRegressors(options ExogenousOptions) (rows [][]float64) {
+	precipitation := w.originalPrecipitation()
+	offset := len(precipitation) - len(w.Items)
+	for t := 0; t <= len(w.Items); t++ {
+		var date time.Time
+		if t < len(w.Items) {
+			date = w.Items[t].Date
+		} else {
+			date = w.Items[len(w.Items)-1].Date.AddDate(0, 0, 1)
+		}
+		rows = append(rows, exogenousRow(options, date, precipitation[:t+offset]))
+	}
+	return
+}
+
+// originalPrecipitation is the precipitation in original units up to the last item, including the days a
+// differenced series dropped.
+func (w *Weathers) originalPrecipitation() (precipitation []float64) {
+	items := w.Items
+	if w.HasOrigin() {
+		items = w.Diff.Origin[:len(w.Items)+w.Diff.Step]
+	}
+	for _, d := range items {
+		precipitation = append(precipitation, d.Precipitation)
+	}
+	return
+}
+
+// exogenousRow builds the regressors of a day from its date and the precipitation of the days before it. Months
+// are dummies against January, since the model already has an intercept.
+func exogenousRow(options ExogenousOptions, date time.Time, precipitation []float64) (row []float64) {
+	for _, regressor := range options.Regressors {
+		switch regressor {
+		case ExogenousMonth:
+			for month := time.February; month <= time.December; month++ {
+				row = append(row, indicator(date.Month() == month))
+			}
+		case ExogenousMonsoon:
+			row = append(row, indicator(monsoonMonths[date.Month()]))
+		case ExogenousFourier:
+			angle := 2 * math.Pi * float64(date.YearDay()) / 365.25
+			for k := 1; k <= options.FourierOrder; k++ {
+				row = append(row, math.Sin(float64(k)*angle), math.Cos(float64(k)*angle))
+			}
+		case ExogenousRain3:
+			row = append(row, trailingSum(precipitation, 3))
+		case ExogenousRain7:
+			row = append(row, trailingSum(precipitation, 7))
+		}
+	}
+	return
+}
+
+func exogenousNames(options ExogenousOptions) (names []string) {
+	for _, regressor := range options.Regressors {
+		switch regressor {
+		case ExogenousMonth:
+			for month := time.February; month <= time.December; month++ {
+				names = append(names, "MONTH_"+month.String()[:3])
+			}
+		case ExogenousMonsoon:
+			names = append(names, "MONSOON")
+		case ExogenousFourier:
+			for k := 1; k <= options.FourierOrder; k++ {
+				names = append(names, fmt.Sprintf("SIN_%d", k), fmt.Sprintf("COS_%d", k))
+			}
+		case ExogenousRain3:
+			names = append(names, "PRECTOTCORR_SUM3")
+		case ExogenousRain7:
+			names = append(names, "PRECTOTCORR_SUM7")
+		}
+	}
+	return
+}
+
+func rainfallWindows(options ExogenousOptions) (windows []int) {
+	for _, regressor := range options.Regressors {
+		switch regressor {
+		case ExogenousRain3:
+			windows = append(windows, 3)
+		case ExogenousRain7:
+			windows = append(windows, 7)
+		}
+	}
+	return
+}
+
+// trailingSum adds up the last window values, or all of them when there are fewer.
+func trailingSum(values []float64, window int) (sum float64) {
+	for i := len(values) - 1; i >= 0 && i >= len(values)-window; i-- {
+		sum += values[i]
+	}
+	return
+}
+
+func indicator(condition bool) float64 {
+	if condition {
+		return 1
+	}
+	return 0
+}
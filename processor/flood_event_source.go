@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"os"
+	"skripsi/database"
+	"strings"
+	"time"
+)
+
+// FloodEventSource supplies the BNPB and news flood reports for a city, in the order they were recorded.
+type FloodEventSource interface {
+	BnpbEvents(ctx context.Context, city string, startDate, endDate time.Time) ([]Bnpb, error)
+	NewsEvents(ctx context.Context, city string, startDate, endDate time.Time) ([]News, error)
+}
+
+// NewFloodEventSource reads the flood_events table when DATA_STORE is postgres and the CSV files in tmp otherwise.
+func NewFloodEventSource(db database.Database) FloodEventSource {
+	if database.DataStore() == database.DataStorePostgres {
+		return NewPostgresFloodEventSource(database.NewFloodEventRepository(db.PostgresDatabase.GetPool()))
+	}
+	return NewCsvFloodEventSource("tmp/bnpb_data.csv", "tmp/data_berita_banjir.csv")
+}
+
+// CsvFloodEventSource parses the BNPB export and the news list on every call.
+type CsvFloodEventSource struct {
+	BnpbPath string
+	NewsPath string
+}
+
+func NewCsvFloodEventSource(bnpbPath, newsPath string) FloodEventSource {
+	return &CsvFloodEventSource{
+		BnpbPath: bnpbPath,
+		NewsPath: newsPath,
+	}
+}
+
+const (
+	BnpbIndexCode      = 1
+	BnpbIndexCityID    = 2
+	BnpbIndexDate      = 3
+	BnpbIndexOccurence = 4
+	BnpbIndexLocation  = 5
+	BnpbIndexCity      = 6
+	BnpbIndexProvince  = 7
+	BnpbIndexCause     = 9
+	NewsIndexCity      = 0
+	NewsIndexDate      = 1
+	NewsIndexLink      = 2
+	bnpbMinimumColumns = BnpbIndexCause + 1
+	newsMinimumColumns = NewsIndexLink + 1
+)
+
+func (s *CsvFloodEventSource) BnpbEvents(ctx context.Context, city string, startDate, endDate time.Time) (events []Bnpb, err error) {
+	records, err := readCsvRecords(s.BnpbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if len(record) < bnpbMinimumColumns || !strings.Contains(strings.ToLower(record[BnpbIndexCity]), city) {
+			continue
+		}
+
+		date, err := time.Parse(DateSlashDMY, record[BnpbIndexDate])
+		if err != nil || date.After(endDate) || date.Before(startDate) {
+			continue
+		}
+
+		events = append(events, Bnpb{
+			Code:      record[BnpbIndexCode],
+			CityID:    record[BnpbIndexCityID],
+			Date:      record[BnpbIndexDate],
+			Occurence: record[BnpbIndexOccurence],
+			Location:  record[BnpbIndexLocation],
+			City:      record[BnpbIndexCity],
+			Province:  record[BnpbIndexProvince],
+			Cause:     record[BnpbIndexCause],
+		})
+	}
+	return events, nil
+}
+
+func (s *CsvFloodEventSource) NewsEvents(ctx context.Context, city string, startDate, endDate time.Time) (events []News, err error) {
+	records, err := readCsvRecords(s.NewsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if len(record) < newsMinimumColumns || !strings.Contains(strings.ToLower(record[NewsIndexCity]), city) {
+			continue
+		}
+
+		date, err := time.Parse(DateSlashYMD, record[NewsIndexDate])
+		if err != nil || date.After(endDate) || date.Before(startDate) {
+			continue
+		}
+
+		events = append(events, News{
+			City: record[NewsIndexCity],
+			Date: record[NewsIndexDate],
+			Link: newsLink(record[NewsIndexLink]),
+		})
+	}
+	return events, nil
+}
+
+// PostgresFloodEventSource reads events imported into the flood_events table.
+type PostgresFloodEventSource struct {
+	Repository database.FloodEventRepository
+}
+
+func NewPostgresFloodEventSource(repository database.FloodEventRepository) FloodEventSource {
+	return &PostgresFloodEventSource{
+		Repository: repository,
+	}
+}
+
+func (s *PostgresFloodEventSource) BnpbEvents(ctx context.Context, city string, startDate, endDate time.Time) (events []Bnpb, err error) {
+	rows, err := s.Repository.FindEvents(ctx, database.FloodEventSourceBnpb, city, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		// A row imported without a code is stored under a generated key, the export itself still has no code.
+		code := row.SourceKey
+		if raw, found := row.Raw["Kode Identitas Bencana"]; found {
+			code = raw
+		}
+		events = append(events, Bnpb{
+			Code:      code,
+			CityID:    row.CityID,
+			Date:      row.Date.Format(DateSlashDMY),
+			Occurence: row.Occurrence,
+			Location:  row.Location,
+			City:      row.City,
+			Province:  row.Province,
+			Cause:     row.Cause,
+		})
+	}
+	return events, nil
+}
+
+func (s *PostgresFloodEventSource) NewsEvents(ctx context.Context, city string, startDate, endDate time.Time) (events []News, err error) {
+	rows, err := s.Repository.FindEvents(ctx, database.FloodEventSourceNews, city, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		events = append(events, News{
+			City: row.City,
+			Date: row.Date.Format(DateSlashYMD),
+			Link: newsLink(row.Link),
+		})
+	}
+	return events, nil
+}
+
+func readCsvRecords(path string) ([][]string, error) {
+	csvFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
+
+	reader := csv.NewReader(csvFile)
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+func newsLink(url string) template.HTML {
+	return template.HTML(fmt.Sprintf("<a class=\"text-blue-800\" href=\"%s\">Link</a>", template.HTMLEscapeString(url)))
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/mathext"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const GrangerSignificanceLevel = 0.05
+
+var grangerVariables = []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M_MAX", "T2M_MIN", "FLOOD"}
+
+// GrangerCausality tests every ordered pair of series, the flood label included, for lags 1 to maxLag.
+// Each lag compares the effect regressed on its own lags against the same regression with the cause's lags added.
+func (w *Weathers) GrangerCausality(maxLag int) (result GrangerCausality) {
+	series := w.grangerSeries()
+	result = GrangerCausality{
+		MaxLag:    maxLag,
+		Variables: grangerVariables,
+		Pairs:     make([][]GrangerCausalityPair, len(series)),
+	}
+
+	for cause := range series {
+		result.Pairs[cause] = make([]GrangerCausalityPair, len(series))
+		for effect := range series {
+			pair := GrangerCausalityPair{
+				Cause:  grangerVariables[cause],
+				Effect: grangerVariables[effect],
+			}
+			if cause != effect {
+				pair.test(series[effect], series[cause], maxLag)
+			}
+			result.Pairs[cause][effect] = pair
+		}
+	}
+	return
+}
+
+func (w *Weathers) grangerSeries() [][]float64 {
+	series := make([][]float64, len(grangerVariables))
+	for _, d := range w.Items {
+		flood := 0.0
+		if d.Flood {
+			flood = 1
+		}
+		for i, value := range []float64{d.WindSpeed, d.RelHumidity, d.Precipitation, d.TempAverage, d.TempMax, d.TempMin, flood} {
+			series[i] = append(series[i], value)
+		}
+	}
+	return series
+}
+
+// test runs the SSR based F and chi-square tests the same way statsmodels' grangercausalitytests does.
+func (p *GrangerCausalityPair) test(effect, cause []float64, maxLag int) {
+	p.MinFPValue, p.MinChi2PValue = 1, 1
+	for lag := 1; lag <= maxLag; lag++ {
+		nobs := len(effect) - lag
+		restricted := mat.NewDense(nobs, lag+1, nil)
+		unrestricted := mat.NewDense(nobs, 2*lag+1, nil)
+		y := mat.NewDense(nobs, 1, nil)
+		for t := 0; t < nobs; t++ {
+			y.Set(t, 0, effect[lag+t])
+			restricted.Set(t, 0, 1)
+			unrestricted.Set(t, 0, 1)
+			for l := 1; l <= lag; l++ {
+				restricted.Set(t, l, effect[lag+t-l])
+				unrestricted.Set(t, l, effect[lag+t-l])
+				unrestricted.Set(t, lag+l, cause[lag+t-l])
+			}
+		}
+
+		_, ssrRestricted, err := leastSquares(restricted, y)
+		if err != nil {
+			p.Error = fmt.Sprintf("lag %d: %v", lag, err)
+			return
+		}
+		_, ssrUnrestricted, err := leastSquares(unrestricted, y)
+		if err != nil {
+			p.Error = fmt.Sprintf("lag %d: %v", lag, err)
+			return
+		}
+
+		dfDenom := nobs - (2*lag + 1)
+		if dfDenom <= 0 || ssrUnrestricted[0] <= 0 {
+			p.Error = fmt.Sprintf("lag %d: not enough observations", lag)
+			return
+		}
+
+		gain := (ssrRestricted[0] - ssrUnrestricted[0]) / ssrUnrestricted[0]
+		result := GrangerCausalityLag{
+			Lag:     lag,
+			F:       gain / float64(lag) * float64(dfDenom),
+			DfNum:   lag,
+			DfDenom: dfDenom,
+			Chi2:    float64(nobs) * gain,
+		}
+		result.FPValue = fSurvival(result.F, float64(lag), float64(dfDenom))
+		result.Chi2PValue = distuv.ChiSquared{K: float64(lag)}.Survival(result.Chi2)
+
+		p.Lags = append(p.Lags, result)
+		p.MinFPValue = math.Min(p.MinFPValue, result.FPValue)
+		p.MinChi2PValue = math.Min(p.MinChi2PValue, result.Chi2PValue)
+	}
+
+	p.Significant = p.MinFPValue < GrangerSignificanceLevel
+	p.MinFPValueStr = strconv.FormatFloat(p.MinFPValue, 'f', 4, 64)
+}
+
+// fSurvival is the upper tail of the F distribution. distuv.F computes it as 1 - CDF, which rounds small p-values to zero.
+func fSurvival(f, d1, d2 float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	return mathext.RegIncBeta(d2/2, d1/2, d2/(d2+d1*f))
+}
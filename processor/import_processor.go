@@ -0,0 +1,411 @@
+package processor
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"skripsi/database"
+	"skripsi/helper"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ImportProcessor interface {
+	ImportBnpb(ctx context.Context, r io.Reader, sourceFile string) (ImportReport, error)
+	ImportNews(ctx context.Context, r io.Reader, sourceFile string) (ImportReport, error)
+	ImportNasa(ctx context.Context, r io.Reader, sourceFile, latitude, longitude string) (ImportReport, error)
+}
+
+type ImportProcessorImpl struct {
+	logger      helper.LoggerHelper
+	floodEvents database.FloodEventRepository
+	weather     database.WeatherRepository
+}
+
+// NewImportProcessor only validates when the repositories are nil, which is what a dry run uses.
+func NewImportProcessor(l helper.LoggerHelper, floodEvents database.FloodEventRepository, weather database.WeatherRepository) ImportProcessor {
+	return &ImportProcessorImpl{
+		logger:      l,
+		floodEvents: floodEvents,
+		weather:     weather,
+	}
+}
+
+const (
+	ImportDatasetBnpb = "bnpb"
+	ImportDatasetNews = "news"
+	ImportDatasetNasa = "nasa"
+
+	importBatchSize = 1000
+	nasaFillValue   = -999
+)
+
+var (
+	bnpbRequiredColumns = []string{"Kode Identitas Bencana", "ID Kabupaten", "Tanggal Kejadian", "Kejadian", "Lokasi", "Kabupaten", "Provinsi", "Penyebab"}
+	bnpbNumericColumns  = []string{"Meninggal", "Hilang", "Terluka", "Rumah Rusak", "Rumah Terendam", "Fasum Rusak"}
+	newsRequiredColumns = []string{"Kota", "Tanggal", "Link"}
+	nasaRequiredColumns = []string{"YEAR", "DOY", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M_MAX", "T2M_MIN"}
+)
+
+// ImportBnpb loads a BNPB disaster export. Rows are keyed by Kode Identitas Bencana, so a code already seen in the file or the table is skipped.
+// Rows without a code, which the flood CSV source still counts, are keyed by bnpbFallbackKey instead.
+func (p *ImportProcessorImpl) ImportBnpb(ctx context.Context, r io.Reader, sourceFile string) (report ImportReport, err error) {
+	report = ImportReport{Dataset: ImportDatasetBnpb, SourceFile: sourceFile}
+	table, err := readImportTable(r, append(append([]string{}, bnpbRequiredColumns...), bnpbNumericColumns...))
+	if err != nil {
+		return report, err
+	}
+
+	firstSeen := make(map[string]int)
+	var events []database.FloodEvent
+	for _, row := range table.rows {
+		report.Rows++
+		key, keyName := strings.TrimSpace(row.get("Kode Identitas Bencana")), "Kode Identitas Bencana"
+		date, reason := validateBnpbRow(row)
+		if reason == "" {
+			if key == "" {
+				key, keyName = bnpbFallbackKey(row, date), "ID Kabupaten, Tanggal Kejadian and Lokasi"
+			}
+			if first, exists := firstSeen[key]; exists {
+				report.Duplicates++
+				reason = fmt.Sprintf("duplicate %s %q, first seen on row %d", keyName, key, first)
+			}
+		}
+		if reason != "" {
+			report.Rejected = append(report.Rejected, RejectedRow{Row: row.line, Reason: reason})
+			continue
+		}
+
+		firstSeen[key] = row.line
+		events = append(events, database.FloodEvent{
+			Source:     database.FloodEventSourceBnpb,
+			SourceKey:  key,
+			Date:       date,
+			City:       strings.TrimSpace(row.get("Kabupaten")),
+			CityID:     strings.TrimSpace(row.get("ID Kabupaten")),
+			Province:   strings.TrimSpace(row.get("Provinsi")),
+			Location:   strings.TrimSpace(row.get("Lokasi")),
+			Occurrence: strings.TrimSpace(row.get("Kejadian")),
+			Cause:      strings.TrimSpace(row.get("Penyebab")),
+			SourceFile: sourceFile,
+			SourceRow:  row.line,
+			Raw:        row.raw(),
+		})
+	}
+
+	report.Accepted = len(events)
+	report.Inserted, err = p.saveFloodEvents(ctx, events)
+	return report, err
+}
+
+// ImportNews loads the news list, keyed by city, date and link.
+func (p *ImportProcessorImpl) ImportNews(ctx context.Context, r io.Reader, sourceFile string) (report ImportReport, err error) {
+	report = ImportReport{Dataset: ImportDatasetNews, SourceFile: sourceFile}
+	table, err := readImportTable(r, newsRequiredColumns)
+	if err != nil {
+		return report, err
+	}
+
+	firstSeen := make(map[string]int)
+	var events []database.FloodEvent
+	for _, row := range table.rows {
+		report.Rows++
+		city := strings.TrimSpace(row.get("Kota"))
+		link := strings.TrimSpace(row.get("Link"))
+		date, reason := validateNewsRow(row)
+		key := strings.Join([]string{strings.ToLower(city), date.Format(DateHyphenYMD), link}, "|")
+		if reason == "" {
+			if first, exists := firstSeen[key]; exists {
+				report.Duplicates++
+				reason = fmt.Sprintf("duplicate of row %d", first)
+			}
+		}
+		if reason != "" {
+			report.Rejected = append(report.Rejected, RejectedRow{Row: row.line, Reason: reason})
+			continue
+		}
+
+		firstSeen[key] = row.line
+		events = append(events, database.FloodEvent{
+			Source:     database.FloodEventSourceNews,
+			SourceKey:  key,
+			Date:       date,
+			City:       city,
+			Link:       link,
+			SourceFile: sourceFile,
+			SourceRow:  row.line,
+			Raw:        row.raw(),
+		})
+	}
+
+	report.Accepted = len(events)
+	report.Inserted, err = p.saveFloodEvents(ctx, events)
+	return report, err
+}
+
+// ImportNasa loads a NASA POWER daily CSV download for one location. Stored days are overwritten.
+func (p *ImportProcessorImpl) ImportNasa(ctx context.Context, r io.Reader, sourceFile, latitude, longitude string) (report ImportReport, err error) {
+	report = ImportReport{Dataset: ImportDatasetNasa, SourceFile: sourceFile}
+	if latitude == "" || longitude == "" {
+		return report, errors.New("latitude and longitude are required for NASA imports")
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return report, err
+	}
+	body, skippedLines := skipNasaHeader(string(content))
+	table, err := readImportTable(strings.NewReader(body), nasaRequiredColumns)
+	if err != nil {
+		return report, err
+	}
+	for i := range table.rows {
+		table.rows[i].line += skippedLines
+	}
+
+	firstSeen := make(map[string]int)
+	var observations []database.WeatherObservation
+	for _, row := range table.rows {
+		report.Rows++
+		observation, reason := validateNasaRow(row)
+		day := observation.Date.Format(DateHyphenYMD)
+		if reason == "" {
+			if first, exists := firstSeen[day]; exists {
+				report.Duplicates++
+				reason = fmt.Sprintf("duplicate date %s, first seen on row %d", day, first)
+			}
+		}
+		if reason != "" {
+			report.Rejected = append(report.Rejected, RejectedRow{Row: row.line, Reason: reason})
+			continue
+		}
+
+		firstSeen[day] = row.line
+		observation.Latitude = latitude
+		observation.Longitude = longitude
+		observation.Source = "import"
+		observations = append(observations, observation)
+	}
+
+	report.Accepted = len(observations)
+	if p.weather == nil {
+		return report, nil
+	}
+	for start := 0; start < len(observations); start += importBatchSize {
+		end := min(start+importBatchSize, len(observations))
+		if err := p.weather.SaveObservations(ctx, observations[start:end]); err != nil {
+			return report, err
+		}
+		report.Inserted = end
+	}
+	return report, nil
+}
+
+func (p *ImportProcessorImpl) saveFloodEvents(ctx context.Context, events []database.FloodEvent) (inserted int, err error) {
+	if p.floodEvents == nil {
+		return 0, nil
+	}
+
+	for start := 0; start < len(events); start += importBatchSize {
+		end := min(start+importBatchSize, len(events))
+		n, err := p.floodEvents.SaveEvents(ctx, events[start:end])
+		inserted += n
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+func validateBnpbRow(row importRow) (date time.Time, reason string) {
+	if reason = row.checkColumnCount(); reason != "" {
+		return
+	}
+	if strings.TrimSpace(row.get("Kabupaten")) == "" {
+		return date, "missing Kabupaten"
+	}
+	if !strings.Contains(strings.ToUpper(row.get("Kejadian")), "BANJIR") {
+		return date, fmt.Sprintf("Kejadian %q is not a flood", row.get("Kejadian"))
+	}
+
+	date, err := time.Parse(DateSlashDMY, strings.TrimSpace(row.get("Tanggal Kejadian")))
+	if err != nil {
+		return date, fmt.Sprintf("invalid Tanggal Kejadian %q, expected DD/MM/YYYY", row.get("Tanggal Kejadian"))
+	}
+
+	for _, column := range bnpbNumericColumns {
+		value := strings.TrimSpace(row.get(column))
+		if value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return date, fmt.Sprintf("invalid %s %q, expected a whole number", column, value)
+		}
+	}
+	return date, ""
+}
+
+// bnpbFallbackKey identifies a row without Kode Identitas Bencana by its regency, date and location, which stay the
+// same when the export is downloaded again.
+func bnpbFallbackKey(row importRow, date time.Time) string {
+	return strings.Join([]string{"ID Kabupaten " + strings.TrimSpace(row.get("ID Kabupaten")), date.Format(DateHyphenYMD),
+		strings.TrimSpace(row.get("Lokasi"))}, "|")
+}
+
+func validateNewsRow(row importRow) (date time.Time, reason string) {
+	if reason = row.checkColumnCount(); reason != "" {
+		return
+	}
+	if strings.TrimSpace(row.get("Kota")) == "" {
+		return date, "missing Kota"
+	}
+
+	date, err := time.Parse(DateSlashYMD, strings.TrimSpace(row.get("Tanggal")))
+	if err != nil {
+		return date, fmt.Sprintf("invalid Tanggal %q, expected YYYY/MM/DD", row.get("Tanggal"))
+	}
+
+	link, err := url.Parse(strings.TrimSpace(row.get("Link")))
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		return date, fmt.Sprintf("invalid Link %q, expected an http(s) URL", row.get("Link"))
+	}
+	return date, ""
+}
+
+func validateNasaRow(row importRow) (observation database.WeatherObservation, reason string) {
+	if reason = row.checkColumnCount(); reason != "" {
+		return
+	}
+
+	year, err := strconv.Atoi(strings.TrimSpace(row.get("YEAR")))
+	if err != nil {
+		return observation, fmt.Sprintf("invalid YEAR %q", row.get("YEAR"))
+	}
+	doy, err := strconv.Atoi(strings.TrimSpace(row.get("DOY")))
+	startOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err != nil || doy < 1 || doy > startOfYear.AddDate(1, 0, -1).YearDay() {
+		return observation, fmt.Sprintf("invalid DOY %q for year %d", row.get("DOY"), year)
+	}
+	observation.Date = startOfYear.AddDate(0, 0, doy-1)
+
+	values := map[string]*float64{
+		"WS10M":       &observation.WindSpeed,
+		"RH2M":        &observation.RelHumidity,
+		"PRECTOTCORR": &observation.Precipitation,
+		"T2M":         &observation.TempAverage,
+		"T2M_MAX":     &observation.TempMax,
+		"T2M_MIN":     &observation.TempMin,
+	}
+	for _, column := range nasaRequiredColumns[2:] {
+		value, err := strconv.ParseFloat(strings.TrimSpace(row.get(column)), 64)
+		if err != nil {
+			return observation, fmt.Sprintf("invalid %s %q", column, row.get(column))
+		}
+		if value == nasaFillValue {
+			return observation, fmt.Sprintf("missing %s, NASA POWER fill value", column)
+		}
+		*values[column] = value
+	}
+	return observation, ""
+}
+
+// importTable is a CSV file from its header row on, with the line number each row started on.
+type importTable struct {
+	columns map[string]int
+	header  []string
+	rows    []importRow
+}
+
+type importRow struct {
+	table  *importTable
+	line   int
+	record []string
+}
+
+// readImportTable takes the first row holding every required column as the header, so title lines above it are skipped.
+func readImportTable(r io.Reader, required []string) (*importTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	table := &importTable{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if table.columns == nil {
+			columns := make(map[string]int)
+			for i, column := range record {
+				columns[strings.TrimSpace(column)] = i
+			}
+			if hasColumns(columns, required) {
+				table.columns = columns
+				table.header = record
+			}
+			continue
+		}
+		table.rows = append(table.rows, importRow{table: table, line: line, record: record})
+	}
+
+	if table.columns == nil {
+		return nil, fmt.Errorf("no header row with the columns %s", strings.Join(required, ", "))
+	}
+	return table, nil
+}
+
+func hasColumns(columns map[string]int, required []string) bool {
+	for _, column := range required {
+		if _, exists := columns[column]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+func (r importRow) get(column string) string {
+	i := r.table.columns[column]
+	if i >= len(r.record) {
+		return ""
+	}
+	return r.record[i]
+}
+
+func (r importRow) checkColumnCount() string {
+	if len(r.record) != len(r.table.header) {
+		return fmt.Sprintf("has %d columns, expected %d", len(r.record), len(r.table.header))
+	}
+	return ""
+}
+
+// raw keeps the original row by column name as provenance.
+func (r importRow) raw() map[string]string {
+	raw := make(map[string]string)
+	for i, column := range r.table.header {
+		if column = strings.TrimSpace(column); column != "" && i < len(r.record) {
+			raw[column] = r.record[i]
+		}
+	}
+	return raw
+}
+
+// skipNasaHeader drops the -BEGIN HEADER- block of a NASA POWER download, if there is one, and reports how many lines it took.
+func skipNasaHeader(content string) (body string, skippedLines int) {
+	content = strings.ReplaceAll(content, "\t", ",")
+	header, body, found := strings.Cut(content, "-END HEADER-")
+	if !found {
+		return content, 0
+	}
+
+	_, body, _ = strings.Cut(body, "\n")
+	return body, strings.Count(header, "\n") + 1
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"skripsi/database"
+	"skripsi/helper"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryFloodEvents stores flood events like the flood_events table, keeping the first event of a source key.
+type memoryFloodEvents struct {
+	events []database.FloodEvent
+	keys   map[string]bool
+}
+
+func (r *memoryFloodEvents) SaveEvents(ctx context.Context, events []database.FloodEvent) (inserted int, err error) {
+	if r.keys == nil {
+		r.keys = make(map[string]bool)
+	}
+	for _, e := range events {
+		key := e.Source + "|" + e.SourceKey
+		if r.keys[key] {
+			continue
+		}
+		r.keys[key] = true
+		r.events = append(r.events, e)
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (r *memoryFloodEvents) FindEvents(ctx context.Context, source, city string, startDate, endDate time.Time) (events []database.FloodEvent, err error) {
+	for _, e := range r.events {
+		if e.Source == source && strings.Contains(strings.ToLower(e.City), strings.ToLower(city)) &&
+			!e.Date.Before(startDate) && !e.Date.After(endDate) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func TestImportBnpbMatchesCsvFloodEventSource(t *testing.T) {
+	const path = "../tmp/bnpb_data.csv"
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	repository := &memoryFloodEvents{}
+	report, err := NewImportProcessor(helper.NewLoggerHelper(), repository, nil).ImportBnpb(context.Background(), file, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rejected := range report.Rejected {
+		if !strings.HasPrefix(rejected.Reason, "duplicate") {
+			t.Errorf("row %d rejected: %s", rejected.Row, rejected.Reason)
+		}
+	}
+
+	csvSource := NewCsvFloodEventSource(path, "")
+	postgresSource := NewPostgresFloodEventSource(repository)
+	startDate, endDate := time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	for city := range cityCoordinates {
+		fromCsv, err := csvSource.BnpbEvents(context.Background(), city, startDate, endDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromPostgres, err := postgresSource.BnpbEvents(context.Background(), city, startDate, endDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The import keeps the first row of a repeated Kode Identitas Bencana, the CSV source every row, so the
+		// imported events are the CSV events without those repeats, on the same flood days.
+		var unique []Bnpb
+		seen := make(map[string]bool)
+		for _, event := range fromCsv {
+			if event.Code == "" || !seen[event.Code] {
+				unique = append(unique, event)
+			}
+			seen[event.Code] = true
+		}
+		if !slices.Equal(fromPostgres, unique) {
+			t.Errorf("%s: %d imported events, %d from the CSV file without repeated codes", city, len(fromPostgres), len(unique))
+		}
+		if !slices.Equal(floodDays(fromPostgres), floodDays(fromCsv)) {
+			t.Errorf("%s: imported flood days differ from the CSV file", city)
+		}
+	}
+
+	// Rows without Kode Identitas Bencana are imported too.
+	for _, want := range []Bnpb{{City: "BOGOR", Date: "22/12/2019"}, {City: "BEKASI", Date: "17/12/2019"}} {
+		events, _ := postgresSource.BnpbEvents(context.Background(), strings.ToLower(want.City), startDate, endDate)
+		if !slices.ContainsFunc(events, func(e Bnpb) bool { return e.Code == "" && e.City == want.City && e.Date == want.Date }) {
+			t.Errorf("no imported %s flood without a code on %s", want.City, want.Date)
+		}
+	}
+}
+
+func floodDays(events []Bnpb) (days []string) {
+	for _, event := range events {
+		days = append(days, event.Date)
+	}
+	slices.Sort(days)
+	return slices.Compact(days)
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"errors"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const DefaultImpulseResponsePeriods = 10
+
+// ImpulseResponse returns the orthogonalized impulse responses 0 to periods days after a one standard deviation
+// shock, Theta_i = Phi_i P with P the lower Cholesky factor of SigmaU as in statsmodels' orth_ma_rep. The shocks
+// are orthogonalized in the order of the variables, so a shock only moves the variables after it on impact.
+func (m *VarModel) ImpulseResponse(periods int) (irf ImpulseResponse, err error) {
+	irf = ImpulseResponse{
+		Periods:   periods,
+		Variables: m.Variables,
+	}
+
+	thetas, err := m.orthogonalMovingAverage(periods + 1)
+	if err != nil {
+		return irf, err
+	}
+	for _, theta := range thetas {
+		irf.Responses = append(irf.Responses, denseRows(theta))
+	}
+	return
+}
+
+// VarianceDecomposition splits the 1 to periods step forecast error variance of every variable into the shares
+// of the orthogonalized shocks, Decomposition[h][i][j] being the share of shocks to j in the h+1 step error of i.
+func (m *VarModel) VarianceDecomposition(periods int) (fevd VarianceDecomposition, err error) {
+	fevd = VarianceDecomposition{
+		Periods:   periods,
+		Variables: m.Variables,
+	}
+
+	thetas, err := m.orthogonalMovingAverage(periods)
+	if err != nil {
+		return fevd, err
+	}
+
+	k := len(m.Coefficients)
+	cumulative := mat.NewDense(k, k, nil)
+	for _, theta := range thetas {
+		var squared mat.Dense
+		squared.MulElem(theta, theta)
+		cumulative.Add(cumulative, &squared)
+
+		shares := make([][]float64, k)
+		for i := 0; i < k; i++ {
+			row := mat.Row(nil, i, cumulative)
+			var total float64
+			for _, value := range row {
+				total += value
+			}
+			shares[i] = make([]float64, k)
+			for j, value := range row {
+				shares[i][j] = value / total
+			}
+		}
+		fevd.Decomposition = append(fevd.Decomposition, shares)
+	}
+
+	fevd.FillString()
+	return
+}
+
+func (m *VarModel) orthogonalMovingAverage(steps int) (thetas []*mat.Dense, err error) {
+	k := len(m.Coefficients)
+	if k == 0 || steps < 1 {
+		return nil, errors.New("the VAR model is not fitted")
+	}
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(symmetric(mat.NewDense(k, k, flatten(m.SigmaU)))); !ok {
+		return nil, errors.New("residual covariance is not positive definite")
+	}
+	var p mat.TriDense
+	chol.LTo(&p)
+
+	for _, phi := range m.MovingAverageCoefficients(steps) {
+		theta := mat.NewDense(k, k, nil)
+		theta.Mul(phi, &p)
+		thetas = append(thetas, theta)
+	}
+	return
+}
+
+// FillString formats the shares at the last horizon in percent.
+func (d *VarianceDecomposition) FillString() {
+	d.FinalStr = nil
+	if len(d.Decomposition) == 0 {
+		return
+	}
+	for _, shares := range d.Decomposition[len(d.Decomposition)-1] {
+		row := make([]string, len(shares))
+		for j, share := range shares {
+			row[j] = strconv.FormatFloat(share*100, 'f', 2, 64) + "%"
+		}
+		d.FinalStr = append(d.FinalStr, row)
+	}
+}
+
+func denseRows(m *mat.Dense) (rows [][]float64) {
+	r, _ := m.Dims()
+	for i := 0; i < r; i++ {
+		rows = append(rows, mat.Row(nil, i, m))
+	}
+	return
+}
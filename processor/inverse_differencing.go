@@ -0,0 +1,125 @@
+package processor
+
+// Differencing a variable d times loses the d values its series started from. A forecast of the differenced
+// series that follows original day t is integrated back with the anchors at t, the series differenced 0 to
+// d-1 times: each order is the anchor of its own order plus the running sum of the order above it.
+
+// Integrate converts forecasts of the differenced series, starting right after differenced item last, back
+// into original units. Forecasts of STL residuals get the extrapolated trend and seasonal components added.
+func (w *Weathers) Integrate(forecasts []Weather, last int) (original []Weather) {
+	values := make([][]float64, len(forecasts))
+	for h, forecast := range forecasts {
+		values[h] = weatherValues(forecast)
+	}
+
+	anchors := w.Diff.anchorsAt(last + w.Diff.Step)
+	for order := w.Diff.Step - 1; order >= 0; order-- {
+		for v := range varVariables {
+			if w.Diff.order(v) <= order {
+				continue
+			}
+			level := anchors[order][v]
+			for h := range values {
+				level += values[h][v]
+				values[h][v] = level
+			}
+		}
+	}
+
+	if w.Diff.Transformation == TransformationStl {
+		for h := range values {
+			for v, component := range w.Diff.Decomposition.components(last, h+1) {
+				values[h][v] += component
+			}
+		}
+	}
+
+	for h, forecast := range forecasts {
+		integrated := weatherFromSlice(values[h])
+		integrated.Date = forecast.Date
+		original = append(original, integrated)
+	}
+	return
+}
+
+// HasOrigin reports whether the series came out of Differencing and can be integrated back.
+func (w *Weathers) HasOrigin() bool {
+	return len(w.Diff.Origin) > 0
+}
+
+// order is the number of times variable v was differenced.
+func (d *DifferencedStatistics) order(v int) int {
+	if d.Orders == nil {
+		return d.Step
+	}
+	return d.Orders[v]
+}
+
+func (d *DifferencedStatistics) integrationOrders() []int {
+	orders := make([]int, len(varVariables))
+	for v := range orders {
+		orders[v] = d.order(v)
+	}
+	return orders
+}
+
+// anchorsAt returns the original series differenced 0 to Step-1 times at original index t, using
+// the binomial expansion of the difference operator. Variables differenced fewer times ignore the higher orders.
+func (d *DifferencedStatistics) anchorsAt(t int) (anchors [][]float64) {
+	for order := 0; order < d.Step; order++ {
+		anchor := make([]float64, len(varVariables))
+		coefficient := 1.0
+		for i := 0; i <= order; i++ {
+			for v, value := range weatherValues(d.Origin[t-i]) {
+				anchor[v] += coefficient * value
+			}
+			coefficient = -coefficient * float64(order-i) / float64(i+1)
+		}
+		anchors = append(anchors, anchor)
+	}
+	return
+}
+
+func (d *DifferencedStatistics) fillAnchors() {
+	d.Anchors = nil
+	if len(d.Origin) == 0 {
+		return
+	}
+
+	last := len(d.Origin) - 1
+	for _, anchor := range d.anchorsAt(last) {
+		weather := weatherFromSlice(anchor)
+		weather.Date = d.Origin[last].Date
+		weather.FillString()
+		d.Anchors = append(d.Anchors, weather)
+	}
+}
+
+// weatherValues is the inverse of weatherFromSlice, in varVariables order.
+func weatherValues(d Weather) []float64 {
+	return []float64{d.WindSpeed, d.RelHumidity, d.Precipitation, d.TempAverage, d.TempMax, d.TempMin}
+}
+
+// Difference is the inverse of Integrate for a single prediction in original units following the last original day,
+// giving it in the units of the differenced series.
+func (w *Weathers) Difference(original Weather) (differenced Weather) {
+	values := weatherValues(original)
+	last := len(w.Diff.Origin) - 1
+	for v := range values {
+		order := w.Diff.order(v)
+		coefficient := 1.0
+		for i := 1; i <= order; i++ {
+			coefficient = -coefficient * float64(order-i+1) / float64(i)
+			values[v] += coefficient * weatherValues(w.Diff.Origin[last+1-i])[v]
+		}
+	}
+	if w.Diff.Transformation == TransformationStl {
+		for v, component := range w.Diff.Decomposition.components(last, 1) {
+			values[v] -= component
+		}
+	}
+
+	differenced = weatherFromSlice(values)
+	differenced.Date = original.Date
+	return
+}
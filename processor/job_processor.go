@@ -0,0 +1,361 @@
+package processor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"skripsi/helper"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JobProcessor interface {
+	HandleSubmitJob(c echo.Context) error
+	HandleGetJob(c echo.Context) error
+	HandleJobEvents(c echo.Context) error
+	HandleCancelJob(c echo.Context) error
+}
+
+type JobProcessorImpl struct {
+	logger     helper.LoggerHelper
+	prediction PredictionProcessor
+	queue      chan *job
+	jobs       map[string]*job
+	mu         sync.Mutex
+}
+
+// job is the internal, lock-guarded state behind a Job snapshot.
+type job struct {
+	mu          sync.Mutex
+	state       Job
+	result      *PredictionResult
+	ctx         context.Context
+	cancel      context.CancelFunc
+	subscribers map[chan Job]struct{}
+}
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+
+	defaultJobWorkers   = 2
+	defaultJobQueueSize = 32
+	jobRetention        = time.Hour
+)
+
+func NewJobProcessor(l helper.LoggerHelper, prediction PredictionProcessor) JobProcessor {
+	workers := envInt("JOB_WORKERS", defaultJobWorkers)
+	queueSize := envInt("JOB_QUEUE_SIZE", defaultJobQueueSize)
+
+	p := &JobProcessorImpl{
+		logger:     l,
+		prediction: prediction,
+		queue:      make(chan *job, queueSize),
+		jobs:       make(map[string]*job),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *JobProcessorImpl) HandleSubmitJob(c echo.Context) error {
+	var request PredictionRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, ApiResponse{
+			Error: "Request body is not a valid prediction request",
+		})
+	}
+
+	params, err := request.Validate()
+	if err != nil {
+		return respondPredictionError(c, err)
+	}
+
+	j, err := p.submit(params)
+	if err != nil {
+		return respondPredictionError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%s/%s", c.Path(), j.ID))
+	return c.JSON(http.StatusAccepted, ApiResponse{
+		Data: j,
+	})
+}
+
+func (p *JobProcessorImpl) HandleGetJob(c echo.Context) error {
+	j, exists := p.get(c.Param("id"))
+	if !exists {
+		return c.JSON(http.StatusNotFound, ApiResponse{
+			Error: "Job not found",
+		})
+	}
+
+	j.mu.Lock()
+	state := j.state
+	state.Result = j.result
+	j.mu.Unlock()
+
+	return c.JSON(http.StatusOK, ApiResponse{
+		Data: state,
+	})
+}
+
+// HandleJobEvents streams job snapshots as Server-Sent Events until the job finishes or the client leaves.
+func (p *JobProcessorImpl) HandleJobEvents(c echo.Context) error {
+	j, exists := p.get(c.Param("id"))
+	if !exists {
+		return c.JSON(http.StatusNotFound, ApiResponse{
+			Error: "Job not found",
+		})
+	}
+
+	events, current := j.subscribe()
+	defer j.unsubscribe(events)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	if err := writeJobEvent(res, current); err != nil || current.Finished() {
+		return err
+	}
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case state := <-events:
+			if err := writeJobEvent(res, state); err != nil {
+				return err
+			}
+			if state.Finished() {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *JobProcessorImpl) HandleCancelJob(c echo.Context) error {
+	j, exists := p.get(c.Param("id"))
+	if !exists {
+		return c.JSON(http.StatusNotFound, ApiResponse{
+			Error: "Job not found",
+		})
+	}
+
+	j.mu.Lock()
+	finished := j.state.Finished()
+	j.mu.Unlock()
+	if finished {
+		return c.JSON(http.StatusConflict, ApiResponse{
+			Error: "Job has already finished",
+		})
+	}
+
+	j.cancel()
+	// A queued job never reaches the pipeline, so mark it here instead of waiting for a worker.
+	j.update(func(state *Job) {
+		if state.Status == JobStatusQueued {
+			state.Status = JobStatusCancelled
+			state.Error = "Prediction was cancelled"
+			state.FinishedAt = time.Now()
+		}
+	})
+
+	return c.JSON(http.StatusAccepted, ApiResponse{
+		Data: j.snapshot(),
+	})
+}
+
+func (p *JobProcessorImpl) submit(params PredictionParams) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, newPredictionError(http.StatusInternalServerError, "Creating job fails")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		state: Job{
+			ID:         id,
+			Status:     JobStatusQueued,
+			StageCount: len(PredictionStages),
+			Params:     params,
+			CreatedAt:  time.Now(),
+		},
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan Job]struct{}),
+	}
+
+	p.mu.Lock()
+	p.pruneLocked()
+	p.jobs[id] = j
+	p.mu.Unlock()
+
+	select {
+	case p.queue <- j:
+	default:
+		cancel()
+		p.mu.Lock()
+		delete(p.jobs, id)
+		p.mu.Unlock()
+		return Job{}, newPredictionError(http.StatusServiceUnavailable, "Job queue is full, try again later")
+	}
+
+	return j.snapshot(), nil
+}
+
+func (p *JobProcessorImpl) get(id string) (*job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	j, exists := p.jobs[id]
+	return j, exists
+}
+
+func (p *JobProcessorImpl) work() {
+	for j := range p.queue {
+		if j.ctx.Err() != nil {
+			continue
+		}
+
+		params := j.snapshot().Params
+		j.update(func(state *Job) {
+			state.Status = JobStatusRunning
+			state.StartedAt = time.Now()
+		})
+
+		result, err := p.prediction.PredictFlood(j.ctx, params, func(stage string) {
+			j.update(func(state *Job) {
+				state.Stage = stage
+				for i, s := range PredictionStages {
+					if s == stage {
+						state.StageIndex = i + 1
+					}
+				}
+				state.Progress = float64(state.StageIndex-1) / float64(state.StageCount)
+			})
+		})
+
+		j.mu.Lock()
+		if err == nil {
+			j.result = &result
+		}
+		j.mu.Unlock()
+
+		j.update(func(state *Job) {
+			state.FinishedAt = time.Now()
+			switch {
+			case err == nil:
+				state.Status = JobStatusSucceeded
+				state.Progress = 1
+			case j.ctx.Err() != nil:
+				state.Status = JobStatusCancelled
+				state.Error = err.Error()
+			default:
+				state.Status = JobStatusFailed
+				state.Error = err.Error()
+			}
+		})
+		j.cancel()
+	}
+}
+
+// pruneLocked drops finished jobs past their retention so results don't pile up in memory.
+func (p *JobProcessorImpl) pruneLocked() {
+	for id, j := range p.jobs {
+		j.mu.Lock()
+		expired := j.state.Finished() && time.Since(j.state.FinishedAt) > jobRetention
+		j.mu.Unlock()
+		if expired {
+			delete(p.jobs, id)
+		}
+	}
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+func (j *job) update(fn func(state *Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(&j.state)
+	for events := range j.subscribers {
+		// Drop the oldest pending snapshot for slow readers, the latest one is all they need.
+		select {
+		case <-events:
+		default:
+		}
+		events <- j.state
+	}
+}
+
+func (j *job) subscribe() (chan Job, Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	events := make(chan Job, 1)
+	j.subscribers[events] = struct{}{}
+	return events, j.state
+}
+
+func (j *job) unsubscribe(events chan Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.subscribers, events)
+}
+
+func (s Job) Finished() bool {
+	return s.Status == JobStatusSucceeded || s.Status == JobStatusFailed || s.Status == JobStatusCancelled
+}
+
+func writeJobEvent(res *echo.Response, state Job) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	event := "progress"
+	if state.Finished() {
+		event = state.Status
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
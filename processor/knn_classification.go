@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"math"
+	"strconv"
+)
+
+const (
+	KnnWeightingUniform  = "uniform"
+	KnnWeightingInverse  = "inverse"
+	KnnWeightingGaussian = "gaussian"
+
+	DefaultKnnThreshold = 0.5
+
+	KnnLabelFlood   = "Flood"
+	KnnLabelNoFlood = "No Flood"
+)
+
+// knnScore is the flood probability given to a test day next to whether it flooded.
+type knnScore struct {
+	probability float64
+	flood       bool
+}
+
+// classify turns the votes of the neighbors, nearest first, into a flood probability. The day is classified as a
+// flood when the probability exceeds the threshold, so an even vote at the default threshold stays No Flood.
+func (o KnnOptions) classify(neighbors []Weather) (result KnnClassification) {
+	var total, flood float64
+	for i, weight := range o.weights(neighbors) {
+		total += weight
+		if neighbors[i].Flood {
+			flood += weight
+			result.FloodNeighbors++
+		}
+	}
+	if total > 0 {
+		result.Probability = flood / total
+	}
+
+	result.Weighting = o.Weighting
+	result.Threshold = o.Threshold
+	result.Flood = result.Probability > o.Threshold
+	result.FillString()
+	return
+}
+
+// weights gives every neighbor the same vote, the inverse of its distance, or the Gaussian kernel of its distance.
+// An exact match takes the whole inverse distance vote like scikit-learn's weights="distance". Without a bandwidth
+// the kernel is as wide as the distance to the farthest neighbor. Votes that all vanish fall back to uniform.
+func (o KnnOptions) weights(neighbors []Weather) []float64 {
+	weights := make([]float64, len(neighbors))
+	var total float64
+	switch o.Weighting {
+	case KnnWeightingInverse:
+		for i, d := range neighbors {
+			if d.Distance == 0 {
+				weights[i] = 1
+				total++
+			}
+		}
+		if total > 0 {
+			return weights
+		}
+		for i, d := range neighbors {
+			weights[i] = 1 / d.Distance
+			total += weights[i]
+		}
+	case KnnWeightingGaussian:
+		bandwidth := o.Bandwidth
+		if bandwidth == 0 && len(neighbors) > 0 {
+			bandwidth = neighbors[len(neighbors)-1].Distance
+		}
+		for i, d := range neighbors {
+			if bandwidth > 0 {
+				weights[i] = math.Exp(-0.5 * (d.Distance / bandwidth) * (d.Distance / bandwidth))
+			}
+			total += weights[i]
+		}
+	}
+
+	if total == 0 || math.IsInf(total, 0) || math.IsNaN(total) {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
+
+// confusionMatrixAt classifies the scores at threshold the same way classify does.
+func confusionMatrixAt(scores []knnScore, threshold float64) (confusionMatrix ConfusionMatrix) {
+	confusionMatrix.Threshold = threshold
+	for _, score := range scores {
+		predicted := score.probability > threshold
+		switch {
+		case score.flood && predicted:
+			confusionMatrix.TruePositive++
+		case !score.flood && predicted:
+			confusionMatrix.FalsePositive++
+		case score.flood && !predicted:
+			confusionMatrix.FalseNegative++
+		default:
+			confusionMatrix.TrueNegative++
+		}
+	}
+	confusionMatrix.Metrics()
+	confusionMatrix.FillString()
+	return
+}
+
+func (c *KnnClassification) FillString() {
+	c.Label = KnnLabelNoFlood
+	if c.Flood {
+		c.Label = KnnLabelFlood
+	}
+	c.ProbabilityStr = strconv.FormatFloat(c.Probability*100, 'f', 0, 64) + "%"
+}
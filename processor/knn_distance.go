@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"math"
+	"slices"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	KnnScalerNone   = "none"
+	KnnScalerMinMax = "minmax"
+	KnnScalerZScore = "zscore"
+	KnnScalerRobust = "robust"
+
+	KnnMetricEuclidean   = "euclidean"
+	KnnMetricManhattan   = "manhattan"
+	KnnMetricChebyshev   = "chebyshev"
+	KnnMetricMinkowski   = "minkowski"
+	KnnMetricMahalanobis = "mahalanobis"
+	KnnMetricCosine      = "cosine"
+
+	DefaultMinkowskiP = 3
+)
+
+// DefaultKnnOptions keeps the unweighted majority vote over raw Euclidean distance the classifier has always used.
+func DefaultKnnOptions() KnnOptions {
+	return KnnOptions{
+		Scaler:     KnnScalerNone,
+		Metric:     KnnMetricEuclidean,
+		MinkowskiP: DefaultMinkowskiP,
+		Weighting:  KnnWeightingUniform,
+		Threshold:  DefaultKnnThreshold,
+	}
+}
+
+// knnScaler maps a day to (value - center) / scale for every variable.
+type knnScaler struct {
+	center []float64
+	scale  []float64
+}
+
+// knnDistance measures how far a day is from the reference days it was fitted on.
+type knnDistance struct {
+	options           KnnOptions
+	scaler            knnScaler
+	inverseCovariance *mat.Dense
+}
+
+// knnReference accumulates the statistics the scaler and the Mahalanobis covariance are fitted from, so a day can
+// join the reference without going over the earlier ones again.
+type knnReference struct {
+	options KnnOptions
+	count   int
+	minimum []float64
+	maximum []float64
+	mean    []float64
+	// scatter sums the outer products of the deviations from the mean, updated with Welford's algorithm.
+	scatter *mat.SymDense
+	// sorted keeps the values of every variable in order for the quantiles of the robust scaler.
+	sorted [][]float64
+}
+
+func newKnnReference(options KnnOptions) *knnReference {
+	k := len(varVariables)
+	return &knnReference{
+		options: options,
+		minimum: make([]float64, k),
+		maximum: make([]float64, k),
+		mean:    make([]float64, k),
+		scatter: mat.NewSymDense(k, nil),
+		sorted:  make([][]float64, k),
+	}
+}
+
+// add puts the values of a day into the reference.
+func (r *knnReference) add(values []float64) {
+	r.count++
+	deviation := make([]float64, len(values))
+	for v, value := range values {
+		if r.count == 1 {
+			r.minimum[v], r.maximum[v] = value, value
+		}
+		r.minimum[v] = min(r.minimum[v], value)
+		r.maximum[v] = max(r.maximum[v], value)
+
+		deviation[v] = value - r.mean[v]
+		r.mean[v] += deviation[v] / float64(r.count)
+
+		if r.options.Scaler == KnnScalerRobust {
+			position, _ := slices.BinarySearch(r.sorted[v], value)
+			r.sorted[v] = slices.Insert(r.sorted[v], position, value)
+		}
+	}
+	for v := range values {
+		for u := v; u < len(values); u++ {
+			r.scatter.SetSym(v, u, r.scatter.At(v, u)+deviation[v]*(values[u]-r.mean[u]))
+		}
+	}
+}
+
+// distance fits the scaler, and for Mahalanobis the covariance, on the reference days only, so the days being
+// classified never take part in the fit.
+func (r *knnReference) distance() (distance knnDistance) {
+	distance.options = r.options
+	distance.scaler = r.scaler()
+	if r.options.Metric == KnnMetricMahalanobis {
+		distance.inverseCovariance = r.inverseCovariance(distance.scaler)
+	}
+	return
+}
+
+// scaler centers and scales like scikit-learn's MinMaxScaler, StandardScaler and RobustScaler. A variable without
+// spread keeps a scale of 1 rather than dividing by zero.
+func (r *knnReference) scaler() (scaler knnScaler) {
+	for v := range varVariables {
+		center, scale := 0.0, 1.0
+		if r.count > 0 {
+			switch r.options.Scaler {
+			case KnnScalerMinMax:
+				center, scale = r.minimum[v], r.maximum[v]-r.minimum[v]
+			case KnnScalerZScore:
+				center = r.mean[v]
+				scale = math.Sqrt(r.scatter.At(v, v) / float64(r.count))
+			case KnnScalerRobust:
+				center = quantile(r.sorted[v], 0.5)
+				scale = quantile(r.sorted[v], 0.75) - quantile(r.sorted[v], 0.25)
+			}
+		}
+		if scale == 0 || math.IsNaN(scale) {
+			scale = 1
+		}
+		scaler.center = append(scaler.center, center)
+		scaler.scale = append(scaler.scale, scale)
+	}
+	return
+}
+
+// inverseCovariance inverts the sample covariance of the scaled reference days through its SVD, dropping
+// directions without variance so a constant or collinear variable cannot make the Mahalanobis distance undefined.
+func (r *knnReference) inverseCovariance(scaler knnScaler) *mat.Dense {
+	k := len(varVariables)
+	inverse := mat.NewDense(k, k, nil)
+	if r.count < 2 {
+		return inverse
+	}
+
+	covariance := mat.NewDense(k, k, nil)
+	for v := 0; v < k; v++ {
+		for u := 0; u < k; u++ {
+			covariance.Set(v, u, r.scatter.At(v, u)/float64(r.count-1)/(scaler.scale[v]*scaler.scale[u]))
+		}
+	}
+
+	var svd mat.SVD
+	if ok := svd.Factorize(covariance, mat.SVDFull); !ok {
+		return inverse
+	}
+	var u, v mat.Dense
+	svd.UTo(&u)
+	svd.VTo(&v)
+	values := svd.Values(nil)
+	tolerance := values[0] * float64(k) * 1e-12
+	for i, value := range values {
+		if value <= tolerance {
+			continue
+		}
+		var outer mat.Dense
+		outer.Outer(1/value, v.ColView(i), u.ColView(i))
+		inverse.Add(inverse, &outer)
+	}
+	return inverse
+}
+
+func (s knnScaler) transform(d Weather) []float64 {
+	values := weatherValues(d)
+	for v := range values {
+		values[v] = (values[v] - s.center[v]) / s.scale[v]
+	}
+	return values
+}
+
+// between is the distance of two scaled days under the chosen metric. Cosine distance is 1 minus the cosine
+// similarity, taken as 1 when either day is the zero vector.
+func (k knnDistance) between(a, b []float64) (distance float64) {
+	switch k.options.Metric {
+	case KnnMetricManhattan:
+		for v := range a {
+			distance += math.Abs(a[v] - b[v])
+		}
+	case KnnMetricChebyshev:
+		for v := range a {
+			distance = max(distance, math.Abs(a[v]-b[v]))
+		}
+	case KnnMetricMinkowski:
+		for v := range a {
+			distance += math.Pow(math.Abs(a[v]-b[v]), k.options.MinkowskiP)
+		}
+		distance = math.Pow(distance, 1/k.options.MinkowskiP)
+	case KnnMetricMahalanobis:
+		difference := make([]float64, len(a))
+		for v := range a {
+			difference[v] = a[v] - b[v]
+		}
+		x := mat.NewVecDense(len(difference), difference)
+		distance = math.Sqrt(max(mat.Inner(x, k.inverseCovariance, x), 0))
+	case KnnMetricCosine:
+		var dot, normA, normB float64
+		for v := range a {
+			dot += a[v] * b[v]
+			normA += a[v] * a[v]
+			normB += b[v] * b[v]
+		}
+		if normA == 0 || normB == 0 {
+			return 1
+		}
+		distance = 1 - dot/math.Sqrt(normA*normB)
+	default:
+		for v := range a {
+			distance += (a[v] - b[v]) * (a[v] - b[v])
+		}
+		distance = math.Sqrt(distance)
+	}
+	return
+}
+
+// quantile interpolates linearly between the closest ranks of sorted values, like numpy's default percentile.
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (position-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
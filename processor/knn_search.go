@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// kdTreeSlack keeps the pruning conservative, a subtree is only skipped when its bound is clearly past the current
+// kth distance so rounding in the metric can never drop a neighbor measuring every day would find.
+const kdTreeSlack = 1e-9
+
+// knnIndex holds the days a KNN vote searches in a KD-tree over their unscaled values. The scalers only shift and
+// stretch every axis, so the tree stays valid as the scaler is refitted, and days can be added as a fold moves
+// forward. The distance is fitted on the indexed days and updated with every added one.
+type knnIndex struct {
+	items     []Weather
+	points    [][]float64
+	root      *kdNode
+	reference *knnReference
+	distance  knnDistance
+}
+
+type kdNode struct {
+	point       int
+	axis        int
+	left, right *kdNode
+}
+
+// knnNeighbor is a candidate of the search, ordered by distance and then by its position among the items so ties
+// resolve the same way on every search path.
+type knnNeighbor struct {
+	item     int
+	distance float64
+}
+
+func (a knnNeighbor) closerThan(b knnNeighbor) bool {
+	return a.distance < b.distance || (a.distance == b.distance && a.item < b.item)
+}
+
+// neighborHeap keeps the farthest of the k best candidates on top.
+type neighborHeap []knnNeighbor
+
+func (h neighborHeap) Len() int           { return len(h) }
+func (h neighborHeap) Less(i, j int) bool { return h[j].closerThan(h[i]) }
+func (h neighborHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x any)        { *h = append(*h, x.(knnNeighbor)) }
+func (h *neighborHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// offer keeps candidate when fewer than k are kept or it is closer than the farthest kept one.
+func (h *neighborHeap) offer(candidate knnNeighbor, k int) {
+	if h.Len() < k {
+		heap.Push(h, candidate)
+	} else if candidate.closerThan((*h)[0]) {
+		(*h)[0] = candidate
+		heap.Fix(h, 0)
+	}
+}
+
+func newKnnIndex(items []Weather, options KnnOptions) (index *knnIndex) {
+	index = &knnIndex{reference: newKnnReference(options)}
+	index.items = make([]Weather, 0, len(items))
+	order := make([]int, len(items))
+	for i, d := range items {
+		index.items = append(index.items, d)
+		index.points = append(index.points, weatherValues(d))
+		index.reference.add(index.points[i])
+		order[i] = i
+	}
+	index.root = index.build(order, 0)
+	index.distance = index.reference.distance()
+	return
+}
+
+// build splits on the median of the axis cycling with depth.
+func (x *knnIndex) build(order []int, depth int) *kdNode {
+	if len(order) == 0 {
+		return nil
+	}
+	axis := depth % len(varVariables)
+	sort.Slice(order, func(i, j int) bool {
+		a, b := x.points[order[i]][axis], x.points[order[j]][axis]
+		return a < b || (a == b && order[i] < order[j])
+	})
+	median := len(order) / 2
+	return &kdNode{
+		point: order[median],
+		axis:  axis,
+		left:  x.build(order[:median], depth+1),
+		right: x.build(order[median+1:], depth+1),
+	}
+}
+
+// add appends d to the searched days.
+func (x *knnIndex) add(d Weather) {
+	point := len(x.points)
+	x.items = append(x.items, d)
+	x.points = append(x.points, weatherValues(d))
+	x.reference.add(x.points[point])
+	x.distance = x.reference.distance()
+
+	next := &x.root
+	depth := 0
+	for *next != nil {
+		node := *next
+		if x.points[point][node.axis] < x.points[node.point][node.axis] {
+			next = &node.left
+		} else {
+			next = &node.right
+		}
+		depth++
+	}
+	*next = &kdNode{point: point, axis: depth % len(varVariables)}
+}
+
+// KNearestNeighbor classifies new against the days of the index with the options it was built with, like
+// Weathers.KNearestNeighbor.
+func (x *knnIndex) KNearestNeighbor(kValue int, new Weather) (neighbors Weathers, result KnnClassification) {
+	for _, neighbor := range x.search(kValue, new) {
+		d := x.items[neighbor.item]
+		d.Distance = neighbor.distance
+		neighbors.Items = append(neighbors.Items, d)
+	}
+	result = x.distance.options.classify(neighbors.Items)
+	return
+}
+
+// search walks the KD-tree nearest side first and keeps the k closest days in a heap, skipping the far side of a
+// split when the distance to the splitting plane alone already exceeds the kth distance. Mahalanobis and cosine
+// distances have no such bound, so their search visits every day but still only keeps k of them.
+func (x *knnIndex) search(kValue int, new Weather) []knnNeighbor {
+	if kValue <= 0 {
+		return nil
+	}
+	distance := x.distance
+	query := distance.scaler.transform(new)
+	point := make([]float64, len(query))
+	candidates := make(neighborHeap, 0, kValue)
+
+	var visit func(node *kdNode)
+	visit = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+		for v, value := range x.points[node.point] {
+			point[v] = (value - distance.scaler.center[v]) / distance.scaler.scale[v]
+		}
+		candidates.offer(knnNeighbor{item: node.point, distance: distance.between(query, point)}, kValue)
+
+		gap := query[node.axis] - point[node.axis]
+		near, far := node.left, node.right
+		if gap >= 0 {
+			near, far = node.right, node.left
+		}
+		visit(near)
+		if candidates.Len() < kValue || distance.planeBound(gap) <= candidates[0].distance*(1+kdTreeSlack) {
+			visit(far)
+		}
+	}
+	visit(x.root)
+
+	nearest := []knnNeighbor(candidates)
+	sort.Slice(nearest, func(i, j int) bool {
+		return nearest[i].closerThan(nearest[j])
+	})
+	return nearest
+}
+
+// planeBound is the least distance to a day on the other side of a splitting plane gap away along its axis.
+func (k knnDistance) planeBound(gap float64) float64 {
+	switch k.options.Metric {
+	case KnnMetricMahalanobis, KnnMetricCosine:
+		return 0
+	default:
+		return math.Abs(gap)
+	}
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// bruteForce measures the distance to every day of the index and sorts them all, the reference the KD-tree search
+// must match, ties included.
+func bruteForce(x *knnIndex, kValue int, new Weather) (nearest []knnNeighbor) {
+	query := x.distance.scaler.transform(new)
+	for i, d := range x.items {
+		nearest = append(nearest, knnNeighbor{item: i, distance: x.distance.between(query, x.distance.scaler.transform(d))})
+	}
+	sort.SliceStable(nearest, func(i, j int) bool {
+		return nearest[i].distance < nearest[j].distance
+	})
+	return nearest[:min(kValue, len(nearest))]
+}
+
+// knnTestDays rounds the values to whole numbers and repeats some days, so many distances tie.
+func knnTestDays(n int, seed int64) (days []Weather) {
+	random := rand.New(rand.NewSource(seed))
+	for len(days) < n {
+		if len(days) > 0 && random.Intn(10) == 0 {
+			days = append(days, days[random.Intn(len(days))])
+			continue
+		}
+		days = append(days, Weather{
+			WindSpeed:     math.Round(3 + 2*random.Float64()),
+			RelHumidity:   math.Round(80 + 6*random.NormFloat64()),
+			Precipitation: math.Round(math.Max(0, 8*random.NormFloat64())),
+			TempAverage:   math.Round(27 + random.NormFloat64()),
+			TempMax:       math.Round(31 + random.NormFloat64()),
+			TempMin:       math.Round(24 + random.NormFloat64()),
+			Flood:         random.Intn(5) == 0,
+		})
+	}
+	return
+}
+
+func TestKnnIndexSearchMatchesBruteForce(t *testing.T) {
+	days := knnTestDays(200, 1)
+	queries := knnTestDays(12, 2)
+	// Some queries sit exactly on indexed days.
+	queries = append(queries, days[3], days[100], days[190])
+
+	scalers := []string{KnnScalerNone, KnnScalerMinMax, KnnScalerZScore, KnnScalerRobust}
+	metrics := []string{KnnMetricEuclidean, KnnMetricManhattan, KnnMetricChebyshev, KnnMetricMinkowski, KnnMetricMahalanobis, KnnMetricCosine}
+	for _, scaler := range scalers {
+		for _, metric := range metrics {
+			t.Run(scaler+"/"+metric, func(t *testing.T) {
+				options := DefaultKnnOptions()
+				options.Scaler, options.Metric = scaler, metric
+
+				// The index grows one day at a time like an evaluation fold, and is searched after every day.
+				index := newKnnIndex(days[:120], options)
+				for j := 120; j <= len(days); j++ {
+					if j > 120 {
+						index.add(days[j-1])
+					}
+					for q, query := range queries {
+						for _, k := range []int{1, 7} {
+							got, want := index.search(k, query), bruteForce(index, k, query)
+							if !slices.Equal(got, want) {
+								t.Fatalf("%d days, query %d, k %d: search %v, brute force %v", j, q, k, got, want)
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestKnnReferenceMatchesBatchFit(t *testing.T) {
+	days := knnTestDays(300, 3)
+	series := make([][]float64, len(varVariables))
+	for _, d := range days {
+		for v, value := range weatherValues(d) {
+			series[v] = append(series[v], value)
+		}
+	}
+
+	for _, scaler := range []string{KnnScalerNone, KnnScalerMinMax, KnnScalerZScore, KnnScalerRobust} {
+		options := DefaultKnnOptions()
+		options.Scaler, options.Metric = scaler, KnnMetricMahalanobis
+		index := newKnnIndex(days[:50], options)
+		for _, d := range days[50:] {
+			index.add(d)
+		}
+		distance := index.distance
+
+		for v, values := range series {
+			sorted := slices.Clone(values)
+			slices.Sort(sorted)
+			center, scale := 0.0, 1.0
+			switch scaler {
+			case KnnScalerMinMax:
+				center, scale = sorted[0], sorted[len(sorted)-1]-sorted[0]
+			case KnnScalerZScore:
+				center, scale = getMean(values), math.Sqrt(variance(values))
+			case KnnScalerRobust:
+				center, scale = quantile(sorted, 0.5), quantile(sorted, 0.75)-quantile(sorted, 0.25)
+			}
+			if scale == 0 {
+				scale = 1
+			}
+			if math.Abs(distance.scaler.center[v]-center) > 1e-9 || math.Abs(distance.scaler.scale[v]-scale) > 1e-9 {
+				t.Errorf("%s variable %d: center %v scale %v, want %v %v", scaler, v,
+					distance.scaler.center[v], distance.scaler.scale[v], center, scale)
+			}
+		}
+
+		// The inverse covariance undoes the sample covariance of the scaled days.
+		var scaled [][]float64
+		for _, d := range days {
+			scaled = append(scaled, distance.scaler.transform(d))
+		}
+		centered := centeredResiduals(scaled)
+		var covariance, product mat.Dense
+		covariance.Mul(centered.T(), centered)
+		covariance.Scale(1/float64(len(scaled)-1), &covariance)
+		product.Mul(distance.inverseCovariance, &covariance)
+		if !mat.EqualApprox(&product, eye(len(varVariables)), 1e-8) {
+			t.Errorf("%s: inverse covariance times covariance is\n%v", scaler, mat.Formatted(&product))
+		}
+	}
+}
+
+func eye(n int) *mat.Dense {
+	identity := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		identity.Set(i, i, 1)
+	}
+	return identity
+}
+
+func benchmarkKnnIndex() (*knnIndex, []Weather) {
+	options := DefaultKnnOptions()
+	options.Scaler = KnnScalerZScore
+	return newKnnIndex(knnTestDays(5800, 4), options), knnTestDays(100, 5)
+}
+
+func BenchmarkKNearestNeighborKdTree(b *testing.B) {
+	index, queries := benchmarkKnnIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.search(5, queries[i%len(queries)])
+	}
+}
+
+func BenchmarkKNearestNeighborBrute(b *testing.B) {
+	index, queries := benchmarkKnnIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bruteForce(index, 5, queries[i%len(queries)])
+	}
+}
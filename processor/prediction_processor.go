@@ -0,0 +1,800 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skripsi/database"
+	"skripsi/helper"
+	"slices"
+	"strings"
+	"time"
+)
+
+type PredictionProcessor interface {
+	PredictFlood(ctx context.Context, params PredictionParams, progress ProgressFunc) (PredictionResult, error)
+}
+
+// ProgressFunc is called with the pipeline stage that is about to start.
+type ProgressFunc func(stage string)
+
+type PredictionProcessorImpl struct {
+	logger      helper.LoggerHelper
+	source      WeatherSource
+	floodEvents FloodEventSource
+	runs        database.PredictionRunRepository
+}
+
+// NewPredictionProcessor records every run through runs unless it is nil.
+func NewPredictionProcessor(l helper.LoggerHelper, source WeatherSource, floodEvents FloodEventSource, runs database.PredictionRunRepository) PredictionProcessor {
+	return &PredictionProcessorImpl{
+		logger:      l,
+		source:      source,
+		floodEvents: floodEvents,
+		runs:        runs,
+	}
+}
+
+const (
+	DefaultLagOrder        = 5
+	DefaultForecastHorizon = 7
+	DefaultConfidenceLevel = 0.95
+
+	// StatusClientClosedRequest is the non-standard status nginx uses for requests abandoned by the client.
+	StatusClientClosedRequest = 499
+
+	StageNasaFetch            = "nasa_fetch"
+	StageBnpbInjection        = "bnpb_injection"
+	StageNewsInjection        = "news_injection"
+	StageDifferencing         = "differencing"
+	StageLagSelection         = "lag_selection"
+	StageCointegration        = "cointegration"
+	StageGrangerCausality     = "granger_causality"
+	StageVectorAutoregression = "vector_autoregression"
+	StageKNearestNeighbor     = "k_nearest_neighbor"
+	StageForecast             = "forecast"
+	StageSmote                = "smote"
+	StageEvaluation           = "evaluation"
+)
+
+var (
+	PredictionStages = []string{
+		StageNasaFetch,
+		StageBnpbInjection,
+		StageNewsInjection,
+		StageDifferencing,
+		StageLagSelection,
+		StageCointegration,
+		StageGrangerCausality,
+		StageVectorAutoregression,
+		StageKNearestNeighbor,
+		StageForecast,
+		StageSmote,
+		StageEvaluation,
+	}
+	predictionStartDateLimit = time.Date(2007, 12, 31, 0, 0, 0, 0, time.Local)
+	predictionEndDateLimit   = time.Date(2024, 10, 1, 0, 0, 0, 0, time.Local)
+	cityCoordinates          = map[string]string{
+		"jakarta barat":   "-6.1674&106.7637",
+		"jakarta timur":   "-6.2250&106.9004",
+		"jakarta pusat":   "-6.1805&106.8284",
+		"jakarta selatan": "-6.2615&106.8106",
+		"jakarta utara":   "-6.1481&106.8998",
+		"bogor":           "-6.2600&106.4800",
+		"depok":           "-6.2350&106.4900",
+		"tangerang":       "-6.1000&106.3000",
+		"bekasi":          "-6.3350&107.1329",
+	}
+)
+
+// CityCoordinates returns the NASA POWER point used for a supported city.
+func CityCoordinates(city string) (latitude, longitude string, found bool) {
+	latlong, found := cityCoordinates[strings.ToLower(strings.TrimSpace(city))]
+	if !found {
+		return "", "", false
+	}
+	latitude, longitude, _ = strings.Cut(latlong, "&")
+	return latitude, longitude, true
+}
+
+// PredictionError carries the HTTP status a failed prediction should be reported with.
+type PredictionError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *PredictionError) Error() string {
+	return e.Message
+}
+
+func newPredictionError(statusCode int, message string) *PredictionError {
+	return &PredictionError{
+		StatusCode: statusCode,
+		Message:    message,
+	}
+}
+
+// Validate checks the raw request and resolves it into the parameters used by the pipeline.
+func (r *PredictionRequest) Validate() (params PredictionParams, err error) {
+	startDate, err := time.Parse(DateHyphenYMD, r.StartDate)
+	if err != nil {
+		return params, newPredictionError(http.StatusBadRequest, "Parsing Date Error")
+	}
+	endDate, err := time.Parse(DateHyphenYMD, r.EndDate)
+	if err != nil {
+		return params, newPredictionError(http.StatusBadRequest, "Parsing Date Error")
+	}
+
+	if startDate.After(endDate) {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Start Date can't be later than End Date")
+	}
+
+	if int(endDate.Sub(startDate).Hours()/24) < 180 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Day Count can't be lower than 180 days to ensure proper calculation")
+	}
+
+	if startDate.Before(predictionStartDateLimit) || endDate.After(predictionEndDateLimit) {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Date can only be within 2008/01/01 until 2024/09/30")
+	}
+
+	if r.KValue <= 0 || r.KValue > 500 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen K Value is not Valid (Must be 1 - 500)")
+	}
+
+	lagOrder := r.LagOrder
+	if lagOrder == 0 {
+		lagOrder = DefaultLagOrder
+	}
+	if lagOrder < 0 || lagOrder > 10 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Lag Order is not Valid (Must be 1 - 10)")
+	}
+
+	lagSelection := strings.ToLower(strings.TrimSpace(r.LagSelection))
+	switch lagSelection {
+	case "", LagCriterionAIC, LagCriterionBIC, LagCriterionHQIC, LagCriterionFPE:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Lag Selection is not Valid (Must be aic, bic, hqic or fpe)")
+	}
+
+	maxLagOrder := r.MaxLagOrder
+	if maxLagOrder == 0 {
+		maxLagOrder = DefaultMaxLagOrder
+	}
+	if maxLagOrder < 0 || maxLagOrder > 15 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Max Lag Order is not Valid (Must be 1 - 15)")
+	}
+
+	if r.SmoteK <= 0 || r.SmoteK > 10 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen SMOTE K Value is not Valid (Must be 1 - 10)")
+	}
+
+	forecastHorizon := r.ForecastHorizon
+	if forecastHorizon == 0 {
+		forecastHorizon = DefaultForecastHorizon
+	}
+	if forecastHorizon < 0 || forecastHorizon > 30 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Forecast Horizon is not Valid (Must be 1 - 30)")
+	}
+
+	confidenceLevel := r.ConfidenceLevel
+	if confidenceLevel == 0 {
+		confidenceLevel = DefaultConfidenceLevel
+	}
+	if confidenceLevel < 0.5 || confidenceLevel >= 1 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Confidence Level is not Valid (Must be 0.5 - 0.99)")
+	}
+
+	stationarity := DefaultStationarityOptions()
+	if policy := strings.ToLower(strings.TrimSpace(r.StationarityPolicy)); policy != "" {
+		stationarity.Policy = policy
+	}
+	switch stationarity.Policy {
+	case StationarityPolicyAdf, StationarityPolicyKpss, StationarityPolicyPhillipsPerron, StationarityPolicyBoth:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Stationarity Policy is not Valid (Must be adf, kpss, pp or both)")
+	}
+
+	if regression := strings.ToLower(strings.TrimSpace(r.AdfRegression)); regression != "" {
+		stationarity.Regression = regression
+	}
+	switch stationarity.Regression {
+	case AdfRegressionNone, AdfRegressionConstant, AdfRegressionConstantTrend:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ADF Regression is not Valid (Must be n, c or ct)")
+	}
+
+	if autolag := strings.ToLower(strings.TrimSpace(r.AdfAutolag)); autolag != "" {
+		stationarity.Autolag = autolag
+	}
+	switch stationarity.Autolag {
+	case AdfAutolagAIC, AdfAutolagBIC, AdfAutolagFixed:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ADF Autolag is not Valid (Must be aic, bic or fixed)")
+	}
+
+	stationarity.MaxLag = r.AdfMaxLag
+	if stationarity.MaxLag < 0 || stationarity.MaxLag > 60 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ADF Max Lag is not Valid (Must be 0 - 60)")
+	}
+
+	if transformation := strings.ToLower(strings.TrimSpace(r.Transformation)); transformation != "" {
+		stationarity.Transformation = transformation
+	}
+	if stationarity.Transformation != TransformationDifference && stationarity.Transformation != TransformationStl {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Transformation is not Valid (Must be difference or stl)")
+	}
+	if r.StlPeriod != 0 {
+		stationarity.Stl.Period = r.StlPeriod
+	}
+	if stationarity.Stl.Period < 7 || stationarity.Stl.Period > 366 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen STL Period is not Valid (Must be 7 - 366)")
+	}
+	if r.StlSeasonal != 0 {
+		stationarity.Stl.Seasonal = r.StlSeasonal
+	}
+	if stationarity.Stl.Seasonal < 7 || stationarity.Stl.Seasonal > 101 || stationarity.Stl.Seasonal%2 == 0 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen STL Seasonal Length is not Valid (Must be odd, 7 - 101)")
+	}
+	stationarity.Stl.Robust = r.StlRobust
+	stationarity.Stl.Periodic = r.StlPeriodic
+	if stationarity.Transformation == TransformationStl && int(endDate.Sub(startDate).Hours()/24)+1 < 2*stationarity.Stl.Period {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "STL needs at least two STL Periods of data, choose a longer date range or a shorter period")
+	}
+
+	model := strings.ToLower(strings.TrimSpace(r.Model))
+	if model == "" {
+		model = ModelVar
+	}
+	if model != ModelVar && model != ModelVecm {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Model is not Valid (Must be var or vecm)")
+	}
+
+	if r.JohansenDetOrder < JohansenNoDeterministic || r.JohansenDetOrder > JohansenLinearTrend {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Johansen Deterministic Order is not Valid (Must be -1, 0 or 1)")
+	}
+
+	irfPeriods := r.IrfPeriods
+	if irfPeriods == 0 {
+		irfPeriods = DefaultImpulseResponsePeriods
+	}
+	if irfPeriods < 0 || irfPeriods > 30 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen IRF Periods is not Valid (Must be 1 - 30)")
+	}
+
+	exogenous := ExogenousOptions{
+		FourierOrder: r.FourierOrder,
+	}
+	if exogenous.FourierOrder == 0 {
+		exogenous.FourierOrder = DefaultFourierOrder
+	}
+	if exogenous.FourierOrder < 0 || exogenous.FourierOrder > 6 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Fourier Order is not Valid (Must be 1 - 6)")
+	}
+	for _, regressor := range r.Exogenous {
+		regressor = strings.ToLower(strings.TrimSpace(regressor))
+		switch regressor {
+		case ExogenousMonth, ExogenousMonsoon, ExogenousFourier, ExogenousRain3, ExogenousRain7:
+		default:
+			return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Exogenous Regressor is not Valid (Must be month, monsoon, fourier, rain3 or rain7)")
+		}
+		if !slices.Contains(exogenous.Regressors, regressor) {
+			exogenous.Regressors = append(exogenous.Regressors, regressor)
+		}
+	}
+	// The month dummies already add up to the monsoon indicator.
+	if slices.Contains(exogenous.Regressors, ExogenousMonth) && slices.Contains(exogenous.Regressors, ExogenousMonsoon) {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Month and Monsoon Regressors can't be combined")
+	}
+	if len(exogenous.Regressors) > 0 && model == ModelVecm {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Exogenous Regressors are only available for the VAR model")
+	}
+
+	baseline := BaselineOptions{
+		SeasonalPeriod: r.SeasonalPeriod,
+		ArimaP:         DefaultArimaP,
+		ArimaD:         ArimaOwnOrder,
+		ArimaQ:         DefaultArimaQ,
+		ArimaMethod:    strings.ToLower(strings.TrimSpace(r.ArimaMethod)),
+	}
+	if baseline.SeasonalPeriod == 0 {
+		baseline.SeasonalPeriod = DefaultSeasonalPeriod
+	}
+	if baseline.SeasonalPeriod < 2 || baseline.SeasonalPeriod > 366 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Seasonal Period is not Valid (Must be 2 - 366)")
+	}
+	// The ARIMA orders are pointers since 0 is a valid order.
+	if r.ArimaP != nil {
+		baseline.ArimaP = *r.ArimaP
+	}
+	if r.ArimaD != nil {
+		baseline.ArimaD = *r.ArimaD
+	}
+	if r.ArimaQ != nil {
+		baseline.ArimaQ = *r.ArimaQ
+	}
+	if baseline.ArimaP < 0 || baseline.ArimaP > 5 || baseline.ArimaQ < 0 || baseline.ArimaQ > 5 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ARIMA Order is not Valid (p and q Must be 0 - 5)")
+	}
+	if baseline.ArimaD < ArimaOwnOrder || baseline.ArimaD > 2 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ARIMA Differencing Order is not Valid (Must be 0 - 2)")
+	}
+	if baseline.ArimaMethod == "" {
+		baseline.ArimaMethod = ArimaMethodCss
+	}
+	if baseline.ArimaMethod != ArimaMethodCss && baseline.ArimaMethod != ArimaMethodMle {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen ARIMA Method is not Valid (Must be css or mle)")
+	}
+
+	knn := DefaultKnnOptions()
+	if scaler := strings.ToLower(strings.TrimSpace(r.KnnScaler)); scaler != "" {
+		knn.Scaler = scaler
+	}
+	switch knn.Scaler {
+	case KnnScalerNone, KnnScalerMinMax, KnnScalerZScore, KnnScalerRobust:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen KNN Scaler is not Valid (Must be none, minmax, zscore or robust)")
+	}
+	if metric := strings.ToLower(strings.TrimSpace(r.KnnMetric)); metric != "" {
+		knn.Metric = metric
+	}
+	switch knn.Metric {
+	case KnnMetricEuclidean, KnnMetricManhattan, KnnMetricChebyshev, KnnMetricMinkowski, KnnMetricMahalanobis, KnnMetricCosine:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen KNN Metric is not Valid (Must be euclidean, manhattan, chebyshev, minkowski, mahalanobis or cosine)")
+	}
+	if r.MinkowskiP != 0 {
+		knn.MinkowskiP = r.MinkowskiP
+	}
+	if knn.MinkowskiP < 1 || knn.MinkowskiP > 10 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen Minkowski P is not Valid (Must be 1 - 10)")
+	}
+	if weighting := strings.ToLower(strings.TrimSpace(r.KnnWeighting)); weighting != "" {
+		knn.Weighting = weighting
+	}
+	switch knn.Weighting {
+	case KnnWeightingUniform, KnnWeightingInverse, KnnWeightingGaussian:
+	default:
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen KNN Weighting is not Valid (Must be uniform, inverse or gaussian)")
+	}
+	knn.Bandwidth = r.KnnBandwidth
+	if knn.Bandwidth < 0 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen KNN Bandwidth is not Valid (Must not be negative)")
+	}
+	if r.KnnThreshold != 0 {
+		knn.Threshold = r.KnnThreshold
+	}
+	if knn.Threshold < 0.01 || knn.Threshold > 0.99 {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "Chosen KNN Threshold is not Valid (Must be 0.01 - 0.99)")
+	}
+
+	latitude, longitude, exists := CityCoordinates(r.City)
+	if !exists {
+		return params, newPredictionError(http.StatusUnprocessableEntity, "City is not available")
+	}
+
+	params = PredictionParams{
+		City:             strings.ToLower(strings.TrimSpace(r.City)),
+		StartDate:        startDate,
+		EndDate:          endDate,
+		KValue:           r.KValue,
+		LagOrder:         lagOrder,
+		LagSelection:     lagSelection,
+		MaxLagOrder:      maxLagOrder,
+		SmoteK:           r.SmoteK,
+		ForecastHorizon:  forecastHorizon,
+		ConfidenceLevel:  confidenceLevel,
+		Stationarity:     stationarity,
+		Model:            model,
+		JohansenDetOrder: r.JohansenDetOrder,
+		IrfPeriods:       irfPeriods,
+		Exogenous:        exogenous,
+		Baseline:         baseline,
+		Knn:              knn,
+		Latitude:         latitude,
+		Longitude:        longitude,
+	}
+	return
+}
+
+func (p *PredictionProcessorImpl) PredictFlood(ctx context.Context, params PredictionParams, progress ProgressFunc) (result PredictionResult, err error) {
+	result, err = p.predictFlood(ctx, params, progress)
+	if p.runs != nil {
+		result.RunID = p.recordRun(params, result, err)
+	}
+	return
+}
+
+func (p *PredictionProcessorImpl) predictFlood(ctx context.Context, params PredictionParams, progress ProgressFunc) (result PredictionResult, err error) {
+	p.logger.LogAndContinue("Start Processing Request")
+	start := time.Now()
+	if progress == nil {
+		progress = func(stage string) {}
+	}
+
+	weathers := Weathers{}
+	nasa := NasaData{}
+	bnpb := BnpbData{}
+	news := NewsData{}
+
+	progress(StageNasaFetch)
+	weathers.PrepareNasa(ctx, p.source, WeatherQuery{
+		City:      params.City,
+		Latitude:  params.Latitude,
+		Longitude: params.Longitude,
+		StartDate: params.StartDate,
+		EndDate:   params.EndDate,
+	}, &nasa)
+	if err = stageError(ctx, weathers.Err, http.StatusBadGateway, "Fetching Weather Data Fails"); err != nil {
+		return
+	}
+
+	weathers.InjectNasa(&nasa)
+	if err = stageError(ctx, weathers.Err, http.StatusBadGateway, "Preparing Data from NASA Power API Fails"); err != nil {
+		return
+	}
+	nasa.Stats()
+
+	progress(StageBnpbInjection)
+	weathers.InjectBnpb(ctx, p.floodEvents, &bnpb, params.StartDate, params.EndDate, params.City)
+	if err = stageError(ctx, weathers.Err, http.StatusInternalServerError, "Preparing Data from BNPB Fails"); err != nil {
+		return
+	}
+
+	progress(StageNewsInjection)
+	weathers.InjectNews(ctx, p.floodEvents, &news, params.StartDate, params.EndDate, params.City)
+	if err = stageError(ctx, weathers.Err, http.StatusInternalServerError, "Preparing Data from News Fails"); err != nil {
+		return
+	}
+
+	progress(StageDifferencing)
+	differencedWeathers := weathers.Differencing(params.Stationarity)
+	if err = stageError(ctx, differencedWeathers.Err, http.StatusUnprocessableEntity, "Differencing Fails, the series could not be made stationary"); err != nil {
+		return
+	}
+	// The decomposition is reported whichever transformation was used, when the range covers two periods.
+	decomposition := differencedWeathers.Diff.Decomposition
+	if params.Stationarity.Transformation != TransformationStl {
+		var decompositionErr error
+		if decomposition, decompositionErr = weathers.SeasonalDecomposition(params.Stationarity.Stl); decompositionErr != nil {
+			decomposition.Error = decompositionErr.Error()
+		}
+	}
+	if decomposition.Error == "" {
+		decomposition.SeasonalOutlook = decomposition.Outlook(params.ForecastHorizon)
+	}
+
+	progress(StageLagSelection)
+	lagSelection, lagSelectionErr := differencedWeathers.SelectLagOrder(params.MaxLagOrder, params.LagSelection)
+	if params.LagSelection != "" {
+		if err = stageError(ctx, lagSelectionErr, http.StatusUnprocessableEntity, "Selecting Lag Order Fails, try a lower Max Lag Order"); err != nil {
+			return
+		}
+		params.LagOrder = lagSelection.Selected
+	} else if lagSelectionErr != nil {
+		p.logger.LogErrAndContinue(lagSelectionErr, "Lag order criteria are not available")
+	}
+
+	// The VECM works on the levels, its lagged differences are those of a VAR of the same order.
+	progress(StageCointegration)
+	cointegration, cointegrationErr := weathers.JohansenTest(params.JohansenDetOrder, params.LagOrder-1)
+	if cointegrationErr != nil {
+		cointegration.Error = cointegrationErr.Error()
+		if params.Model == ModelVecm {
+			if err = stageError(ctx, cointegrationErr, http.StatusUnprocessableEntity, "Cointegration Test Fails, try a lower Lag Order"); err != nil {
+				return
+			}
+		}
+		p.logger.LogErrAndContinue(cointegrationErr, "Cointegration test is not available")
+	}
+
+	// Without cointegration a VECM is just the VAR on the differences, so the VAR is kept.
+	model := ModelVar
+	var vecmModel VecmModel
+	if params.Model == ModelVecm && cointegration.TraceRank > 0 {
+		var vecmErr error
+		vecmModel, vecmErr = weathers.FitVecm(params.JohansenDetOrder, cointegration.TraceRank, params.LagOrder-1)
+		if err = stageError(ctx, vecmErr, http.StatusUnprocessableEntity, "Fitting VECM Fails, try a lower Lag Order"); err != nil {
+			return
+		}
+		model = ModelVecm
+	}
+
+	progress(StageGrangerCausality)
+	grangerCausality := differencedWeathers.GrangerCausality(params.LagOrder)
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	progress(StageVectorAutoregression)
+	// With exogenous regressors the VARX takes the place of the VAR from here on.
+	var varModel VarModel
+	var varErr error
+	if len(params.Exogenous.Regressors) > 0 {
+		varModel, varErr = differencedWeathers.FitVarx(params.LagOrder, params.Exogenous)
+	} else {
+		varModel, varErr = differencedWeathers.FitVectorAutoregression(params.LagOrder)
+	}
+	if errors.Is(varErr, ErrRedundantRainfallSum) {
+		err = stageError(ctx, varErr, http.StatusUnprocessableEntity, "Rainfall Sums Repeat the Precipitation Lags of an Undifferenced Series, choose a Lag Order below the Sum Window")
+		return
+	}
+	if varErr != nil && params.Stationarity.Transformation == TransformationStl {
+		err = stageError(ctx, varErr, http.StatusUnprocessableEntity, "Fitting VAR Fails, the STL residuals are degenerate, try a periodic STL or a longer date range")
+		return
+	}
+	if err = stageError(ctx, varErr, http.StatusUnprocessableEntity, "Fitting VAR Fails, the design matrix is singular, try a lower Lag Order or fewer Exogenous Regressors"); err != nil {
+		return
+	}
+	varDiagnostics, diagnosticsErr := varModel.Diagnostics()
+	if diagnosticsErr != nil {
+		varDiagnostics.Error = diagnosticsErr.Error()
+	}
+
+	var prediction, originalPrediction Weather
+	if model == ModelVecm {
+		originalPrediction = weathers.VecmPrediction(vecmModel)
+		prediction = differencedWeathers.Difference(originalPrediction)
+	} else {
+		prediction = differencedWeathers.Predict(varModel)
+		originalPrediction = differencedWeathers.Integrate([]Weather{prediction}, len(differencedWeathers.Items)-1)[0]
+	}
+	prediction.FillString()
+	originalPrediction.FillString()
+	// The VECM responses are those of its VAR in levels, so they do not have to die out.
+	structuralModel := varModel
+	if model == ModelVecm {
+		structuralModel = vecmModel.VarRepresentation()
+	}
+	impulseResponse, irfErr := structuralModel.ImpulseResponse(params.IrfPeriods)
+	if irfErr != nil {
+		impulseResponse.Error = irfErr.Error()
+	}
+	varianceDecomposition, fevdErr := structuralModel.VarianceDecomposition(params.IrfPeriods)
+	if fevdErr != nil {
+		varianceDecomposition.Error = fevdErr.Error()
+	}
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	progress(StageKNearestNeighbor)
+	neighbors, knnResult := differencedWeathers.KNearestNeighbor(params.KValue, prediction, false, params.Knn)
+	originalNeighbors, originalKnnResult := weathers.KNearestNeighbor(params.KValue, originalPrediction, false, params.Knn)
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	progress(StageForecast)
+	var forecast Forecast
+	if model == ModelVecm {
+		forecast = weathers.VecmForecastHorizon(vecmModel, params.ForecastHorizon, params.ConfidenceLevel, params.KValue, params.Knn)
+	} else {
+		forecast = differencedWeathers.ForecastHorizon(varModel, params.ForecastHorizon, params.ConfidenceLevel, params.KValue, params.Knn)
+	}
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	progress(StageSmote)
+	oversampled := differencedWeathers.SmoteOversampling(params.SmoteK, nasa)
+	smoteNeighbors, smoteKnnResult := oversampled.KNearestNeighbor(params.KValue, prediction, true, params.Knn)
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	progress(StageEvaluation)
+	vectorAutoregressionEvaluation, originalVectorAutoregressionEvaluation := differencedWeathers.VectorAutoregressionEval(ctx, 6, 5, params.LagOrder, ExogenousOptions{})
+	var varxEvaluation, originalVarxEvaluation Weathers
+	if len(params.Exogenous.Regressors) > 0 {
+		varxEvaluation, originalVarxEvaluation = differencedWeathers.VectorAutoregressionEval(ctx, 6, 5, params.LagOrder, params.Exogenous)
+	}
+	baselineEvaluation := differencedWeathers.BaselineEval(ctx, 6, 5, params.Baseline)
+	if differencedWeathers.HasOrigin() {
+		baselineEvaluation.AddModel(ModelVar, originalVectorAutoregressionEvaluation)
+	} else {
+		baselineEvaluation.AddModel(ModelVar, vectorAutoregressionEvaluation)
+	}
+	if len(params.Exogenous.Regressors) > 0 {
+		if differencedWeathers.HasOrigin() {
+			baselineEvaluation.AddModel(ModelVarx, originalVarxEvaluation)
+		} else {
+			baselineEvaluation.AddModel(ModelVarx, varxEvaluation)
+		}
+	}
+	baselineEvaluation.Rank()
+	knnEval := differencedWeathers.KNearestNeighborEval(ctx, 6, 5, params.KValue, params.LagOrder, false, params.Knn)
+	smoteKnnEval := oversampled.KNearestNeighborEval(ctx, 6, 5, params.KValue, params.LagOrder, true, params.Knn)
+	if err = stageError(ctx, nil, 0, ""); err != nil {
+		return
+	}
+
+	result = PredictionResult{
+		Params:                                 params,
+		Nasa:                                   nasa,
+		Bnpb:                                   bnpb,
+		News:                                   news,
+		Weathers:                               weathers,
+		DifferencedWeathers:                    differencedWeathers,
+		SeasonalDecomposition:                  decomposition,
+		GrangerCausality:                       grangerCausality,
+		LagSelection:                           lagSelection,
+		VectorAutoregressionModel:              varModel,
+		VectorAutoregressionDiagnostics:        varDiagnostics,
+		Cointegration:                          cointegration,
+		Model:                                  model,
+		VecmModel:                              vecmModel,
+		ImpulseResponse:                        impulseResponse,
+		VarianceDecomposition:                  varianceDecomposition,
+		Oversampled:                            oversampled,
+		Prediction:                             prediction,
+		OriginalPrediction:                     originalPrediction,
+		VectorAutoregressionEvaluation:         vectorAutoregressionEvaluation,
+		OriginalVectorAutoregressionEvaluation: originalVectorAutoregressionEvaluation,
+		VarxEvaluation:                         varxEvaluation,
+		OriginalVarxEvaluation:                 originalVarxEvaluation,
+		BaselineEvaluation:                     baselineEvaluation,
+		Neighbors:                              neighbors,
+		KNNResult:                              knnResult,
+		OriginalNeighbors:                      originalNeighbors,
+		OriginalKNNResult:                      originalKnnResult,
+		KNNEvaluation:                          knnEval,
+		Forecast:                               forecast,
+		SmoteNeighbors:                         smoteNeighbors,
+		SmoteKNNResult:                         smoteKnnResult,
+		SmoteKNNEvaluation:                     smoteKnnEval,
+	}
+	result.Statistics = Statistics{
+		Ref: StatisticsReference{
+			Nasa:                &result.Nasa,
+			Bnpb:                &result.Bnpb,
+			News:                &result.News,
+			Weathers:            &result.Weathers,
+			DifferencedWeathers: &result.DifferencedWeathers,
+			Smote:               &result.Oversampled,
+		},
+	}
+
+	result.Statistics.FillStatistics(params.StartDate, params.EndDate, params.City)
+	result.Weathers.FillString()
+	result.DifferencedWeathers.FillString()
+	result.Neighbors.FillString()
+	result.OriginalNeighbors.FillString()
+	result.Oversampled.FillString()
+	result.SmoteNeighbors.FillString()
+	result.Duration = time.Since(start).Milliseconds()
+	p.logger.LogAndContinue("Done Processing Request")
+
+	return
+}
+
+// recordRun stores the run on its own context so cancelled and failed runs are kept too. Failures are only logged.
+func (p *PredictionProcessorImpl) recordRun(params PredictionParams, result PredictionResult, predictionErr error) (id int64) {
+	run := database.PredictionRun{
+		City:       params.City,
+		StartDate:  params.StartDate,
+		EndDate:    params.EndDate,
+		Status:     database.PredictionRunSucceeded,
+		DurationMs: result.Duration,
+	}
+
+	var err error
+	if run.Params, err = json.Marshal(params); err != nil {
+		p.logger.LogErrAndContinue(err, "Failed encoding prediction run parameters")
+		return 0
+	}
+
+	if predictionErr != nil {
+		run.Status = database.PredictionRunFailed
+		run.Error = predictionErr.Error()
+		if predictionErr, ok := predictionErr.(*PredictionError); ok && predictionErr.StatusCode == StatusClientClosedRequest {
+			run.Status = database.PredictionRunCancelled
+		}
+	} else if run.Result, err = json.Marshal(result); err != nil {
+		p.logger.LogErrAndContinue(err, "Failed encoding prediction run result")
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	id, err = p.runs.CreateRun(ctx, run)
+	if err != nil {
+		p.logger.LogErrAndContinue(err, "Failed recording prediction run")
+		return 0
+	}
+	return id
+}
+
+// stageError reports cancellation first, since a cancelled fetch also surfaces as a stage error.
+func stageError(ctx context.Context, err error, statusCode int, message string) error {
+	if ctx.Err() != nil {
+		return newPredictionError(StatusClientClosedRequest, "Prediction was cancelled")
+	}
+	if err != nil {
+		return newPredictionError(statusCode, message)
+	}
+	return nil
+}
+
+// ViewData maps the prediction result onto the fields used by the mainv2 template.
+func (r *PredictionResult) ViewData() map[string]interface{} {
+	return map[string]interface{}{
+		"NasaHeaders":                        []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN"},
+		"NasaStats":                          []Nasa{r.Nasa.Max, r.Nasa.Min, r.Nasa.Mean, r.Nasa.Variance, r.Nasa.StdDev},
+		"NasaValues":                         r.Nasa.Items,
+		"BnpbHeaders":                        []string{"Kode Identitas Bencana", "ID Kabupaten", "Tanggal Kejadian", "Kejadian", "Lokasi", "Kabupaten", "Provinsi", "Penyebab"},
+		"BnpbValues":                         r.Bnpb.Items,
+		"NewsHeaders":                        []string{"Kota", "Tanggal", "Link Berita"},
+		"NewsValues":                         r.News.Items,
+		"WeatherAndFloodHeaders":             []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
+		"WeatherAndFloodValues":              r.Weathers.Items,
+		"DifferencedWeatherAndFloodHeaders":  []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
+		"DifferencedWeatherAndFloodValues":   r.DifferencedWeathers.Items,
+		"DifferencedWeatherAndFloodStats":    r.DifferencedWeathers.Diff,
+		"GrangerCausality":                   r.GrangerCausality,
+		"LagSelection":                       r.LagSelection,
+		"LagOrder":                           r.Params.LagOrder,
+		"VarDiagnostics":                     r.VectorAutoregressionDiagnostics,
+		"CointegrationHeaders":               []string{"H0", "EIGENVALUE", "TRACE", "TRACE 5%", "MAX EIGENVALUE", "MAX EIGENVALUE 5%"},
+		"Cointegration":                      r.Cointegration,
+		"Model":                              r.Model,
+		"RequestedModel":                     r.Params.Model,
+		"ImpulseResponse":                    r.ImpulseResponse,
+		"VarianceDecomposition":              r.VarianceDecomposition,
+		"VectorAutoregressionHeaders":        []string{"TRAIN-TEST (%)", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN"},
+		"VectorAutoregressionValues":         r.VectorAutoregressionEvaluation.Items,
+		"VectorAutoregressionResult":         weatherKeyValues(r.Prediction),
+		"VectorAutoregressionOriginalResult": weatherKeyValues(r.OriginalPrediction),
+		"VectorAutoregressionOriginalValues": r.OriginalVectorAutoregressionEvaluation.Items,
+		"VarxValues":                         r.VarxEvaluation.Items,
+		"VarxOriginalValues":                 r.OriginalVarxEvaluation.Items,
+		"ExogenousNames":                     r.VectorAutoregressionModel.ExogenousNames,
+		"SeasonalDecomposition":              r.SeasonalDecomposition,
+		"Transformation":                     r.Params.Stationarity.Transformation,
+		"BaselineHeaders":                    []string{"MODEL", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN"},
+		"BaselineEvaluation":                 r.BaselineEvaluation,
+		"Knn":                                r.Params.Knn,
+		"KNNHeaders":                         []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "DISTANCE", "FLOOD"},
+		"KNNValues":                          r.Neighbors.Items,
+		"KNNResult":                          r.KNNResult,
+		"OriginalKNNResult":                  r.OriginalKNNResult,
+		"KNNEvalHeaders":                     []string{"TRAIN-TEST (%)", "TP", "FP", "TN", "FN", "ACCURACY", "PRECISION", "RECALL", "F1-SCORE", "BALANCED ACCURACY", "MCC", "KAPPA", "BRIER", "ROC-AUC", "PR-AUC"},
+		"KNNEvalValues":                      r.KNNEvaluation,
+		"ForecastHeaders":                    []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD RISK", "KNN"},
+		"Forecast":                           r.Forecast,
+		"SMOTEHeaders":                       []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
+		"SMOTEValues":                        r.Oversampled.SynthItems,
+		"SMOTEKNNHeaders":                    []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "DISTANCE", "FLOOD"},
+		"SMOTEKNNValues":                     r.SmoteNeighbors.Items,
+		"SMOTEKNNResult":                     r.SmoteKNNResult,
+		"SMOTEKNNEvalHeaders":                []string{"TRAIN-TEST (%)", "TP", "FP", "TN", "FN", "ACCURACY", "PRECISION", "RECALL", "F1-SCORE", "BALANCED ACCURACY", "MCC", "KAPPA", "BRIER", "ROC-AUC", "PR-AUC"},
+		"SMOTEKNNEvalValues":                 r.SmoteKNNEvaluation,
+		"Statistics":                         r.Statistics,
+		"Latitude":                           r.Params.Latitude,
+		"Longitude":                          r.Params.Longitude,
+		"Timestamp":                          time.Now().Unix(),
+	}
+}
+
+// ChartData is the subset of the result the mainv2 charts read from JSData.
+func (r *PredictionResult) ChartData() map[string]interface{} {
+	return map[string]interface{}{
+		"Forecast":              r.Forecast,
+		"ImpulseResponse":       r.ImpulseResponse,
+		"VarianceDecomposition": r.VarianceDecomposition,
+		"SeasonalDecomposition": r.SeasonalDecomposition,
+		"KNNEvaluation":         r.KNNEvaluation,
+		"SmoteKNNEvaluation":    r.SmoteKNNEvaluation,
+	}
+}
+
+func weatherKeyValues(w Weather) []KeyValue {
+	return []KeyValue{
+		{Key: "WS10M", Value: w.WindSpeedStr},
+		{Key: "RH2M", Value: w.RelHumidityStr},
+		{Key: "PRECTOTCORR", Value: w.PrecipitationStr},
+		{Key: "T2M", Value: w.TempAverageStr},
+		{Key: "T2M_MAX", Value: w.TempMaxStr},
+		{Key: "T2M_MIN", Value: w.TempMinStr},
+	}
+}
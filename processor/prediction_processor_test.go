@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"skripsi/helper"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cityWeatherSource returns a different synthetic series for every city, seeded by the city name.
+type cityWeatherSource struct{}
+
+func (cityWeatherSource) Fetch(ctx context.Context, query WeatherQuery) (items []Nasa, err error) {
+	return citySeries(query.City, query.StartDate, query.EndDate), nil
+}
+
+func citySeries(city string, startDate, endDate time.Time) (items []Nasa) {
+	seed := int64(len(city))
+	for _, r := range city {
+		seed = seed*31 + int64(r)
+	}
+	random := rand.New(rand.NewSource(seed))
+	offset := float64(seed%7) / 2
+	for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		season := math.Sin(2 * math.Pi * float64(date.YearDay()) / 365.25)
+		items = append(items, Nasa{
+			Date:          date,
+			WindSpeed:     3 + offset/4 + random.Float64(),
+			RelHumidity:   78 + offset + 5*season + 3*random.NormFloat64(),
+			Precipitation: math.Max(0, 8*season+6*random.NormFloat64()),
+			TempAverage:   27 + offset - season + random.NormFloat64()/2,
+			TempMax:       31 + offset - season + random.NormFloat64()/2,
+			TempMin:       24 + offset - season + random.NormFloat64()/2,
+		})
+	}
+	return
+}
+
+// wetDaysFloodSource reports a flood on the wettest days of the city's series.
+type wetDaysFloodSource struct{}
+
+func (wetDaysFloodSource) BnpbEvents(ctx context.Context, city string, startDate, endDate time.Time) (events []Bnpb, err error) {
+	for _, n := range citySeries(city, startDate, endDate) {
+		if n.Precipitation > 15 {
+			events = append(events, Bnpb{Date: n.Date.Format(DateSlashDMY), City: city})
+		}
+	}
+	return
+}
+
+func (wetDaysFloodSource) NewsEvents(ctx context.Context, city string, startDate, endDate time.Time) ([]News, error) {
+	return nil, nil
+}
+
+func TestPredictFloodConcurrentCities(t *testing.T) {
+	processor := NewPredictionProcessor(helper.NewLoggerHelper(), cityWeatherSource{}, wetDaysFloodSource{}, nil)
+	cities := []string{"jakarta barat", "bogor", "depok", "bekasi"}
+
+	params := make([]PredictionParams, len(cities))
+	results := make([]PredictionResult, len(cities))
+	errs := make([]error, len(cities))
+	var wg sync.WaitGroup
+	for i, city := range cities {
+		wg.Add(1)
+		go func(i int, city string) {
+			defer wg.Done()
+			request := PredictionRequest{City: city, StartDate: "2015-01-01", EndDate: "2015-12-31", KValue: 5, SmoteK: 3}
+			if params[i], errs[i] = request.Validate(); errs[i] != nil {
+				return
+			}
+			results[i], errs[i] = processor.PredictFlood(context.Background(), params[i], nil)
+		}(i, city)
+	}
+	wg.Wait()
+
+	for i, city := range cities {
+		if errs[i] != nil {
+			t.Fatalf("%s: %v", city, errs[i])
+		}
+		want := citySeries(city, params[i].StartDate, params[i].EndDate)
+		got := results[i].Weathers.Items
+		if results[i].Params.City != city || len(got) != len(want) {
+			t.Fatalf("%s: got %d days of %q, want %d", city, len(got), results[i].Params.City, len(want))
+		}
+		for j := range want {
+			if !got[j].Date.Equal(want[j].Date) || got[j].TempAverage != want[j].TempAverage || got[j].Precipitation != want[j].Precipitation {
+				t.Fatalf("%s: day %d is %s %v, want %s %v", city, j, got[j].Date.Format(DateHyphenYMD), got[j].TempAverage,
+					want[j].Date.Format(DateHyphenYMD), want[j].TempAverage)
+			}
+		}
+		if len(results[i].Bnpb.Items) == 0 {
+			t.Errorf("%s: no flood days were injected", city)
+		}
+	}
+}
@@ -1,15 +1,28 @@
 package processor
 
-import "skripsi/helper"
+import (
+	"skripsi/database"
+	"skripsi/helper"
+)
 
 type Processor struct {
 	WebProcessor     WebProcessor
 	WebViewProcessor WebViewProcessor
+	ApiProcessor     ApiProcessor
+	JobProcessor     JobProcessor
 }
 
-func NewProcessor(l helper.LoggerHelper) Processor {
+func NewProcessor(l helper.LoggerHelper, cache helper.CacheHelper, db database.Database) Processor {
+	var runs database.PredictionRunRepository
+	if database.DataStore() == database.DataStorePostgres {
+		runs = database.NewPredictionRunRepository(db.PostgresDatabase.GetPool())
+	}
+
+	prediction := NewPredictionProcessor(l, NewWeatherSource(l, cache, db), NewFloodEventSource(db), runs)
 	return Processor{
-		WebProcessor:     NewWebProcessor(l),
+		WebProcessor:     NewWebProcessor(l, prediction),
 		WebViewProcessor: NewWebViewProcessor(l),
+		ApiProcessor:     NewApiProcessor(l, prediction),
+		JobProcessor:     NewJobProcessor(l, prediction),
 	}
 }
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"errors"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrSingularDesignMatrix = errors.New("design matrix is singular, the regressors are linearly dependent")
+
+// leastSquares fits y = Xb by QR and returns the coefficients with the sum of squared residuals per response column.
+func leastSquares(x, y mat.Matrix) (coef *mat.Dense, ssr []float64, err error) {
+	rows, cols := x.Dims()
+	if rows <= cols {
+		return nil, nil, ErrSingularDesignMatrix
+	}
+
+	var qr mat.QR
+	qr.Factorize(x)
+	coef = &mat.Dense{}
+	if err := qr.SolveTo(coef, false, y); err != nil {
+		return nil, nil, ErrSingularDesignMatrix
+	}
+
+	var residuals mat.Dense
+	residuals.Mul(x, coef)
+	residuals.Sub(y, &residuals)
+
+	_, responses := y.Dims()
+	ssr = make([]float64, responses)
+	for j := 0; j < responses; j++ {
+		column := mat.Col(nil, j, &residuals)
+		ssr[j] = mat.Dot(mat.NewVecDense(rows, column), mat.NewVecDense(rows, column))
+	}
+	return coef, ssr, nil
+}
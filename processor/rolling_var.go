@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// rollingVar refits the VAR, or the VARX when exogenous has regressors, on the items before every origin of a
+// rolling-origin evaluation. Moving the origin one day forward adds a single row to the regression, so X'X and X'Y
+// are updated in place and the Cholesky factor of X'X takes a rank-one update, O(p²) for p regressors, instead of
+// rebuilding and refactorizing the whole design for every test day. The coefficients are those of fitVarModel on
+// the same items up to rounding.
+type rollingVar struct {
+	weathers      *Weathers
+	lagOrder      int
+	exogenous     ExogenousOptions
+	data          [][]float64
+	exogenousRows [][]float64
+	precipitation []float64
+	err           error
+
+	// rows is the number of items whose regression rows are in xTx and xTy.
+	rows       int
+	regressors []float64
+	xTx        *mat.SymDense
+	xTy        *mat.Dense
+	chol       mat.Cholesky
+	factorized bool
+}
+
+func (w *Weathers) newRollingVar(lagOrder int, exogenous ExogenousOptions) (r *rollingVar) {
+	r = &rollingVar{
+		weathers:  w,
+		lagOrder:  lagOrder,
+		exogenous: exogenous,
+		data:      w.varMatrix(),
+		rows:      lagOrder,
+	}
+	if lagOrder < 1 || len(w.Items) == 0 {
+		r.err = fmt.Errorf("lag order %d is not valid for %d observations", lagOrder, len(w.Items))
+		return
+	}
+
+	// The regressors of a day only depend on its date and the rainfall before it, so the rows of the whole series
+	// serve every origin.
+	numOfExogenous := 0
+	if len(exogenous.Regressors) > 0 {
+		r.err = w.checkRainfallWindows(lagOrder, exogenous)
+		r.exogenousRows = w.ExogenousRegressors(exogenous)
+		r.precipitation = w.originalPrecipitation()
+		numOfExogenous = len(r.exogenousRows[0])
+	}
+
+	size := 1 + len(varVariables)*lagOrder + numOfExogenous
+	r.regressors = make([]float64, size)
+	r.xTx = mat.NewSymDense(size, nil)
+	r.xTy = mat.NewDense(size, len(varVariables), nil)
+	return
+}
+
+// fit returns the model of the items before origin. Origins can only move forward. The model carries the
+// coefficients needed to forecast but neither residuals nor their covariance.
+func (r *rollingVar) fit(origin int) (model VarModel, err error) {
+	if r.err != nil {
+		return model, r.err
+	}
+
+	for ; r.rows < origin; r.rows++ {
+		varRegressors(r.regressors, r.data, r.exogenousRows, r.lagOrder, r.rows)
+		x := mat.NewVecDense(len(r.regressors), r.regressors)
+		r.xTx.SymRankOne(r.xTx, 1, x)
+		r.xTy.RankOne(r.xTy, 1, x, mat.NewVecDense(len(varVariables), r.data[r.rows]))
+		if r.factorized {
+			r.factorized = r.chol.SymRankOne(&r.chol, 1, x)
+		}
+	}
+
+	nobs := origin - r.lagOrder
+	if nobs <= len(r.regressors) {
+		return model, ErrSingularDesignMatrix
+	}
+	// Until the rows make X'X positive definite every origin factorizes it from scratch, like fitVarModel does.
+	if !r.factorized {
+		if r.factorized = r.chol.Factorize(r.xTx); !r.factorized {
+			return model, ErrSingularDesignMatrix
+		}
+	}
+
+	var coef mat.Dense
+	if err := r.chol.SolveTo(&coef, r.xTy); err != nil {
+		return model, ErrSingularDesignMatrix
+	}
+
+	model = VarModel{
+		LagOrder:     r.lagOrder,
+		Variables:    varVariables,
+		Nobs:         nobs,
+		Coefficients: make([][]float64, len(varVariables)),
+	}
+	for i := range varVariables {
+		model.Coefficients[i] = mat.Col(nil, i, &coef)
+	}
+	if len(r.exogenous.Regressors) > 0 {
+		model.Exogenous = r.exogenous
+		model.ExogenousNames = exogenousNames(r.exogenous)
+	}
+	return
+}
+
+// Predict fits the items before origin and forecasts the item at origin, as VectorAutoregression or Varx would on
+// those items.
+func (r *rollingVar) Predict(origin int) (prediction Weather, err error) {
+	model, err := r.fit(origin)
+	if err != nil {
+		return prediction, err
+	}
+
+	history := r.data[:origin]
+	if len(r.exogenous.Regressors) == 0 {
+		return weatherFromSlice(model.Forecast(history)), nil
+	}
+	date := r.weathers.Items[origin-1].Date.AddDate(0, 0, 1)
+	offset := len(r.precipitation) - len(r.weathers.Items)
+	return weatherFromSlice(model.ForecastExogenous(history, exogenousRow(r.exogenous, date, r.precipitation[:origin+offset]))), nil
+}
@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// rollingVarTestWeathers simulates a seasonal VAR(1) with rainfall, starting on January 1 so the month dummies of a
+// VARX leave X'X singular until every month was seen.
+func rollingVarTestWeathers(n int) (w Weathers) {
+	random := rand.New(rand.NewSource(7))
+	previous := []float64{3, 80, 5, 27, 31, 24}
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for t := 0; t < n; t++ {
+		date := start.AddDate(0, 0, t)
+		season := math.Sin(2 * math.Pi * float64(date.YearDay()) / 365.25)
+		values := []float64{
+			1 + 0.6*previous[0] + 0.3*random.NormFloat64(),
+			30 + 0.6*previous[1] + 0.05*previous[2] + 4*season + random.NormFloat64(),
+			math.Max(0, 2+0.4*previous[2]+6*season+4*random.NormFloat64()),
+			10 + 0.6*previous[3] - season + 0.3*random.NormFloat64(),
+			12 + 0.6*previous[4] - season + 0.3*random.NormFloat64(),
+			9 + 0.6*previous[5] - season + 0.3*random.NormFloat64(),
+		}
+		weather := weatherFromSlice(values)
+		weather.Date = date
+		w.Items = append(w.Items, weather)
+		previous = values
+	}
+	return
+}
+
+func TestRollingVarMatchesBatchFit(t *testing.T) {
+	w := rollingVarTestWeathers(420)
+	data := w.varMatrix()
+	lagOrder := 2
+
+	tests := []struct {
+		name      string
+		exogenous ExogenousOptions
+	}{
+		{"var", ExogenousOptions{}},
+		{"varx", ExogenousOptions{Regressors: []string{ExogenousMonth, ExogenousFourier, ExogenousRain7}, FourierOrder: 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var exogenousRows [][]float64
+			if len(test.exogenous.Regressors) > 0 {
+				exogenousRows = w.ExogenousRegressors(test.exogenous)
+			}
+			precipitation := w.originalPrecipitation()
+
+			rolling := w.newRollingVar(lagOrder, test.exogenous)
+			singular, fitted := 0, 0
+			for j := 1; j < len(data); j++ {
+				got, rollingErr := rolling.Predict(j)
+
+				var rows [][]float64
+				if exogenousRows != nil {
+					rows = exogenousRows[:j]
+				}
+				model, err := fitVarModel(data[:j], rows, lagOrder, 0)
+				if errors.Is(err, ErrSingularDesignMatrix) {
+					if !errors.Is(rollingErr, ErrSingularDesignMatrix) {
+						t.Fatalf("origin %d: batch fit is singular, rolling fit returned %v", j, rollingErr)
+					}
+					singular++
+					continue
+				}
+				if err != nil {
+					t.Fatalf("origin %d: %v", j, err)
+				}
+				if rollingErr != nil {
+					t.Fatalf("origin %d: batch fit succeeded, rolling fit returned %v", j, rollingErr)
+				}
+				fitted++
+
+				var want []float64
+				if exogenousRows == nil {
+					want = model.Forecast(data[:j])
+				} else {
+					want = model.ForecastExogenous(data[:j], exogenousRow(test.exogenous, w.Items[j-1].Date.AddDate(0, 0, 1), precipitation[:j]))
+				}
+				for v, value := range weatherValues(got) {
+					if math.Abs(value-want[v]) > 1e-6*max(1, math.Abs(want[v])) {
+						t.Fatalf("origin %d %s: rolling %v, batch %v", j, varVariables[v], value, want[v])
+					}
+				}
+			}
+			if singular == 0 || fitted == 0 {
+				t.Fatalf("%d singular and %d fitted origins, want both", singular, fitted)
+			}
+		})
+	}
+}
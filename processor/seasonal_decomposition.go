@@ -0,0 +1,454 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	TransformationDifference = "difference"
+	TransformationStl        = "stl"
+
+	DefaultStlPeriod   = 365
+	DefaultStlSeasonal = 7
+
+	// Without robustness STL runs 2 inner loops, with it 5 inner loops in each of 15 robustness iterations, as in
+	// statsmodels.
+	stlInnerIterations       = 2
+	stlRobustInnerIterations = 5
+	stlRobustIterations      = 15
+)
+
+// StlOptions sets the period of the seasonal component and the length of the LOESS smoothing the cycle
+// subseries. The trend and low-pass lengths are derived from them like statsmodels' STL does. Robust STL
+// downweights outliers, such as the heaviest rain days. Periodic replaces the subseries smoothing with the
+// subseries mean like R's s.window = "periodic", which keeps the seasonal component from following the noise
+// when the range only covers a few periods.
+type StlOptions struct {
+	Period   int  `json:"period"`
+	Seasonal int  `json:"seasonal"`
+	Robust   bool `json:"robust"`
+	Periodic bool `json:"periodic"`
+}
+
+func DefaultStlOptions() StlOptions {
+	return StlOptions{
+		Period:   DefaultStlPeriod,
+		Seasonal: DefaultStlSeasonal,
+	}
+}
+
+// SeasonalDecomposition splits every variable into trend, seasonal and residual series with STL, the
+// seasonal-trend decomposition using LOESS of Cleveland et al. (1990).
+func (w *Weathers) SeasonalDecomposition(options StlOptions) (decomposition SeasonalDecomposition, err error) {
+	decomposition = SeasonalDecomposition{
+		Options:   options,
+		Variables: varVariables,
+	}
+	if len(w.Items) < 2*options.Period {
+		return decomposition, fmt.Errorf("STL needs at least two periods of %d days", options.Period)
+	}
+
+	series := make([][]float64, len(varVariables))
+	for _, d := range w.Items {
+		decomposition.Dates = append(decomposition.Dates, d.Date)
+		for v, value := range weatherValues(d) {
+			series[v] = append(series[v], value)
+		}
+	}
+
+	for v := range series {
+		trend, seasonal, residual := stl(series[v], options)
+		decomposition.Trend = append(decomposition.Trend, trend)
+		decomposition.Seasonal = append(decomposition.Seasonal, seasonal)
+		decomposition.Residual = append(decomposition.Residual, residual)
+		decomposition.TrendStrength = append(decomposition.TrendStrength, componentStrength(trend, residual))
+		decomposition.SeasonalStrength = append(decomposition.SeasonalStrength, componentStrength(seasonal, residual))
+	}
+	decomposition.FillString()
+	return
+}
+
+// deseasonalize is the STL strategy of Differencing, the VAR models the residuals of the decomposition and
+// forecasts are put back into original units by adding the extrapolated trend and seasonal components.
+func (w *Weathers) deseasonalize(options StationarityOptions) (deseasonalized Weathers) {
+	decomposition, err := w.SeasonalDecomposition(options.Stl)
+	if err != nil {
+		fmt.Printf("[DIFFERENCING] error decomposing the series: %v", err)
+		deseasonalized.Err = err
+		return
+	}
+
+	results := make([]VariableStationarity, len(varVariables))
+	for v := range varVariables {
+		result, err := TestStationarity(decomposition.Residual[v], options)
+		if err != nil {
+			fmt.Printf("[DIFFERENCING] error testing %s for stationarity: %v", varVariables[v], err)
+			deseasonalized.Err = err
+			return
+		}
+		result.Variable = varVariables[v]
+		results[v] = result
+	}
+
+	for t, d := range w.Items {
+		values := make([]float64, len(varVariables))
+		for v := range values {
+			values[v] = decomposition.Residual[v][t]
+		}
+		weather := weatherFromSlice(values)
+		weather.Date = d.Date
+		weather.Flood = d.Flood
+		deseasonalized.Items = append(deseasonalized.Items, weather)
+	}
+	deseasonalized.Diff.Transformation = TransformationStl
+	deseasonalized.Diff.Orders = make([]int, len(varVariables))
+	deseasonalized.Diff.Policy = options.Policy
+	deseasonalized.Diff.Stationarity = results
+	deseasonalized.Diff.Origin = w.Items
+	deseasonalized.Diff.Decomposition = decomposition
+	deseasonalized.Diff.splits = &stlSplits{}
+	return
+}
+
+// components extrapolates the trend and seasonal components h days past original day t: the trend goes on
+// along its last slope and the seasonal component repeats the last observed season. An origin t after the end of
+// the decomposition is a day of an evaluation split, which can be years past its training days, so the trend is
+// held at its last value there instead of drifting along the slope, and only the season is extrapolated.
+func (d *SeasonalDecomposition) components(t, h int) []float64 {
+	last := len(d.Dates) - 1
+	values := make([]float64, len(d.Variables))
+	for v := range values {
+		if t > last {
+			values[v] = d.Trend[v][last] + d.Seasonal[v][d.seasonIndex(last, h+t-last)]
+			continue
+		}
+		slope := d.Trend[v][t] - d.Trend[v][t-1]
+		values[v] = d.Trend[v][t] + float64(h)*slope + d.Seasonal[v][d.seasonIndex(t, h)]
+	}
+	return values
+}
+
+// seasonIndex is the day of the last observed season up to t in the same phase as h days past t.
+func (d *SeasonalDecomposition) seasonIndex(t, h int) int {
+	period := d.Options.Period
+	return t + h - period*((h+period-1)/period)
+}
+
+// Outlook is the seasonal component of the steps days after the last one, how far the season alone moves
+// every variable from its trend.
+func (d *SeasonalDecomposition) Outlook(steps int) (outlook []Weather) {
+	last := len(d.Dates) - 1
+	for h := 1; h <= steps; h++ {
+		values := make([]float64, len(d.Variables))
+		for v := range values {
+			values[v] = d.Seasonal[v][d.seasonIndex(last, h)]
+		}
+		seasonal := weatherFromSlice(values)
+		seasonal.Date = d.Dates[last].AddDate(0, 0, h)
+		seasonal.FillString()
+		outlook = append(outlook, seasonal)
+	}
+	return
+}
+
+// stlSplit decomposes only the first trainSize days again and continues their residuals with the days after them
+// minus the components known at the end of the training: the last trend and the last observed season.
+func (w *Weathers) stlSplit(trainSize int) (split Weathers, err error) {
+	decomposition, err := w.Diff.splits.decompose(w.Diff.Origin, trainSize, w.Diff.Decomposition.Options)
+	if err != nil {
+		return split, err
+	}
+
+	split.Diff = w.Diff
+	split.Diff.Decomposition = decomposition
+	for t, d := range w.Items {
+		values := weatherValues(w.Diff.Origin[t])
+		for v := range values {
+			if t < trainSize {
+				values[v] = decomposition.Residual[v][t]
+			} else {
+				values[v] -= decomposition.components(t-1, 1)[v]
+			}
+		}
+		weather := weatherFromSlice(values)
+		weather.Date = d.Date
+		weather.Flood = d.Flood
+		split.Items = append(split.Items, weather)
+	}
+	return
+}
+
+// stlSplits keeps the decomposition of the training days of every evaluation split, so the VAR, VARX and KNN
+// evaluations of one prediction decompose a split once.
+type stlSplits struct {
+	mu             sync.Mutex
+	decompositions map[int]stlSplitDecomposition
+}
+
+type stlSplitDecomposition struct {
+	decomposition SeasonalDecomposition
+	err           error
+}
+
+// decompose returns the decomposition of the first trainSize days of origin, decomposing them on the first call.
+// Without splits every call decomposes again.
+func (s *stlSplits) decompose(origin []Weather, trainSize int, options StlOptions) (SeasonalDecomposition, error) {
+	train := Weathers{Items: origin[:trainSize]}
+	if s == nil {
+		return train.SeasonalDecomposition(options)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	split, found := s.decompositions[trainSize]
+	if !found {
+		split.decomposition, split.err = train.SeasonalDecomposition(options)
+		if s.decompositions == nil {
+			s.decompositions = make(map[int]stlSplitDecomposition)
+		}
+		s.decompositions[trainSize] = split
+	}
+	return split.decomposition, split.err
+}
+
+// stl runs the inner loop of Cleveland et al. (1990) and, when robust, the outer loop updating the robustness
+// weights from the residuals.
+func stl(values []float64, options StlOptions) (trend, seasonal, residual []float64) {
+	n, period := len(values), options.Period
+	seasonalLength := options.Seasonal
+	trendLength := nextOdd(int(math.Ceil(1.5 * float64(period) / (1 - 1.5/float64(seasonalLength)))))
+	lowPassLength := nextOdd(period + 1)
+
+	inner, outer := stlInnerIterations, 0
+	if options.Robust {
+		inner, outer = stlRobustInnerIterations, stlRobustIterations
+	}
+
+	trend = make([]float64, n)
+	seasonal = make([]float64, n)
+	weights := make([]float64, n)
+	for t := range weights {
+		weights[t] = 1
+	}
+
+	for iteration := 0; iteration <= outer; iteration++ {
+		for i := 0; i < inner; i++ {
+			detrended := make([]float64, n)
+			for t := range values {
+				detrended[t] = values[t] - trend[t]
+			}
+
+			// Smooth every cycle subseries and extend it one period on both sides, then take out its low
+			// frequencies so they end up in the trend.
+			cycle := make([]float64, n+2*period)
+			for k := 0; k < period; k++ {
+				var subseries, subweights []float64
+				for t := k; t < n; t += period {
+					subseries = append(subseries, detrended[t])
+					subweights = append(subweights, weights[t])
+				}
+				for j := -1; j <= len(subseries); j++ {
+					var value float64
+					if options.Periodic {
+						value = weightedMean(subseries, subweights)
+					} else {
+						var ok bool
+						if value, ok = loess(subseries, subweights, seasonalLength, float64(j)); !ok {
+							value = subseries[min(max(j, 0), len(subseries)-1)]
+						}
+					}
+					cycle[k+(j+1)*period] = value
+				}
+			}
+			lowPass := movingAverage(movingAverage(movingAverage(cycle, period), period), 3)
+			lowPass = loessSmooth(lowPass, nil, lowPassLength)
+			for t := range seasonal {
+				seasonal[t] = cycle[period+t] - lowPass[t]
+			}
+
+			deseasonalized := make([]float64, n)
+			for t := range values {
+				deseasonalized[t] = values[t] - seasonal[t]
+			}
+			trend = loessSmooth(deseasonalized, weights, trendLength)
+		}
+
+		if iteration < outer {
+			weights = robustnessWeights(values, trend, seasonal)
+		}
+	}
+
+	residual = make([]float64, n)
+	for t := range values {
+		residual[t] = values[t] - trend[t] - seasonal[t]
+	}
+	return
+}
+
+// loessSmooth fits loess at every position of values.
+func loessSmooth(values, weights []float64, q int) []float64 {
+	smoothed := make([]float64, len(values))
+	for t := range values {
+		value, ok := loess(values, weights, q, float64(t))
+		if !ok {
+			value = values[t]
+		}
+		smoothed[t] = value
+	}
+	return smoothed
+}
+
+// loess fits a line to values at positions 0 to n-1 by weighted least squares with tricube weights over the q
+// nearest positions of x, times the robustness weights, and returns it at x. It is false when all weights are zero.
+func loess(values, weights []float64, q int, x float64) (float64, bool) {
+	n := len(values)
+	left, right := 0, n-1
+	if q < n {
+		center := int(math.Round(x))
+		left = min(max(center-q/2, 0), n-q)
+		right = left + q - 1
+	}
+	h := math.Max(x-float64(left), float64(right)-x)
+	if q > n {
+		h += float64((q - n) / 2)
+	}
+
+	local := make([]float64, right-left+1)
+	var total float64
+	for i := range local {
+		distance := math.Abs(float64(left+i) - x)
+		switch {
+		case distance <= 0.001*h:
+			local[i] = 1
+		case distance <= 0.999*h:
+			ratio := distance / h
+			tricube := 1 - ratio*ratio*ratio
+			local[i] = tricube * tricube * tricube
+		}
+		if weights != nil {
+			local[i] *= weights[left+i]
+		}
+		total += local[i]
+	}
+	if total <= 0 {
+		return 0, false
+	}
+
+	var mean float64
+	for i := range local {
+		local[i] /= total
+		mean += local[i] * float64(left+i)
+	}
+	var spread float64
+	for i := range local {
+		spread += local[i] * (float64(left+i) - mean) * (float64(left+i) - mean)
+	}
+	if math.Sqrt(spread) > 0.001*float64(n-1) {
+		slope := (x - mean) / spread
+		for i := range local {
+			local[i] *= slope*(float64(left+i)-mean) + 1
+		}
+	}
+
+	var fitted float64
+	for i := range local {
+		fitted += local[i] * values[left+i]
+	}
+	return fitted, true
+}
+
+// weightedMean falls back to the plain mean when all weights are zero.
+func weightedMean(values, weights []float64) float64 {
+	var sum, total float64
+	for i, value := range values {
+		sum += weights[i] * value
+		total += weights[i]
+	}
+	if total <= 0 {
+		return getMean(values)
+	}
+	return sum / total
+}
+
+func movingAverage(values []float64, length int) []float64 {
+	averaged := make([]float64, len(values)-length+1)
+	var sum float64
+	for t, value := range values {
+		sum += value
+		if t >= length {
+			sum -= values[t-length]
+		}
+		if t >= length-1 {
+			averaged[t-length+1] = sum / float64(length)
+		}
+	}
+	return averaged
+}
+
+// robustnessWeights are the bisquare weights of the residuals scaled by six times their median absolute value.
+func robustnessWeights(values, trend, seasonal []float64) []float64 {
+	absolute := make([]float64, len(values))
+	for t := range values {
+		absolute[t] = math.Abs(values[t] - trend[t] - seasonal[t])
+	}
+	sorted := slices.Clone(absolute)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	median := sorted[middle]
+	if len(sorted)%2 == 0 {
+		median = (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	weights := make([]float64, len(values))
+	h := 6 * median
+	for t, value := range absolute {
+		switch {
+		case value <= 0.001*h:
+			weights[t] = 1
+		case value <= 0.999*h:
+			ratio := value / h
+			weights[t] = (1 - ratio*ratio) * (1 - ratio*ratio)
+		}
+	}
+	return weights
+}
+
+// componentStrength is max(0, 1 - Var(residual) / Var(component + residual)) of Wang, Smith and Hyndman (2006).
+func componentStrength(component, residual []float64) float64 {
+	combined := make([]float64, len(component))
+	for t := range component {
+		combined[t] = component[t] + residual[t]
+	}
+	total := variance(combined)
+	if total == 0 {
+		return 0
+	}
+	return math.Max(0, 1-variance(residual)/total)
+}
+
+func variance(values []float64) (sum float64) {
+	mean := getMean(values)
+	for _, value := range values {
+		sum += (value - mean) * (value - mean)
+	}
+	return sum / float64(len(values))
+}
+
+func nextOdd(value int) int {
+	if value%2 == 0 {
+		return value + 1
+	}
+	return value
+}
+
+func (d *SeasonalDecomposition) FillString() {
+	d.TrendStrengthStr, d.SeasonalStrengthStr = nil, nil
+	for v := range d.Variables {
+		d.TrendStrengthStr = append(d.TrendStrengthStr, strconv.FormatFloat(d.TrendStrength[v], 'f', 4, 64))
+		d.SeasonalStrengthStr = append(d.SeasonalStrengthStr, strconv.FormatFloat(d.SeasonalStrength[v], 'f', 4, 64))
+	}
+}
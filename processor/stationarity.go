@@ -0,0 +1,464 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const (
+	StationarityTestAdf            = "adf"
+	StationarityTestKpss           = "kpss"
+	StationarityTestPhillipsPerron = "pp"
+
+	// StationarityPolicyBoth needs ADF to reject a unit root and KPSS to keep stationarity.
+	StationarityPolicyAdf            = "adf"
+	StationarityPolicyKpss           = "kpss"
+	StationarityPolicyPhillipsPerron = "pp"
+	StationarityPolicyBoth           = "both"
+
+	AdfRegressionNone          = "n"
+	AdfRegressionConstant      = "c"
+	AdfRegressionConstantTrend = "ct"
+
+	AdfAutolagAIC   = "aic"
+	AdfAutolagBIC   = "bic"
+	AdfAutolagFixed = "fixed"
+
+	StationaritySignificanceLevel = 0.05
+	MaxDifferencingSteps          = 5
+)
+
+// StationarityOptions follow statsmodels' adfuller and kpss and arch's PhillipsPerron. With AdfAutolagFixed
+// MaxLag is the number of lagged differences in ADF and the bandwidth of the KPSS and Phillips-Perron
+// long run variance. Otherwise ADF picks its lags minimizing Autolag from 0 to MaxLag, a zero MaxLag being
+// Schwert's 12 * (nobs / 100)^(1/4), KPSS uses the Hobijn et al. bandwidth and Phillips-Perron Schwert's.
+// Transformation picks how Differencing makes the series stationary, differencing or taking the STL residuals.
+type StationarityOptions struct {
+	Policy         string     `json:"policy"`
+	Regression     string     `json:"regression"`
+	MaxLag         int        `json:"max_lag"`
+	Autolag        string     `json:"autolag"`
+	Transformation string     `json:"transformation"`
+	Stl            StlOptions `json:"stl"`
+}
+
+func DefaultStationarityOptions() StationarityOptions {
+	return StationarityOptions{
+		Policy:         StationarityPolicyAdf,
+		Regression:     AdfRegressionConstant,
+		Autolag:        AdfAutolagAIC,
+		Transformation: TransformationDifference,
+		Stl:            DefaultStlOptions(),
+	}
+}
+
+// TestStationarity runs every test on the series, Stationary follows the policy.
+func TestStationarity(series []float64, options StationarityOptions) (result VariableStationarity, err error) {
+	adf, err := AdfTest(series, options)
+	if err != nil {
+		return result, err
+	}
+	kpss, err := KpssTest(series, options)
+	if err != nil {
+		return result, err
+	}
+	pp, err := PhillipsPerronTest(series, options)
+	if err != nil {
+		return result, err
+	}
+
+	result.Tests = []StationarityTest{adf, kpss, pp}
+	switch options.Policy {
+	case StationarityPolicyKpss:
+		result.Stationary = kpss.Stationary
+	case StationarityPolicyPhillipsPerron:
+		result.Stationary = pp.Stationary
+	case StationarityPolicyBoth:
+		result.Stationary = adf.Stationary && kpss.Stationary
+	default:
+		result.Stationary = adf.Stationary
+	}
+	return
+}
+
+// AdfTest runs the augmented Dickey-Fuller test. The null hypothesis is a unit root, so a p-value below
+// StationaritySignificanceLevel rejects it and the series is taken as stationary.
+func AdfTest(series []float64, options StationarityOptions) (result StationarityTest, err error) {
+	if options.Regression == "" {
+		options.Regression = AdfRegressionConstant
+	}
+	if _, known := adfCritical[options.Regression]; !known {
+		return result, fmt.Errorf("unknown ADF regression %q", options.Regression)
+	}
+	trendTerms := adfTrendTerms(options.Regression)
+
+	maxLag := options.MaxLag
+	if maxLag <= 0 && options.Autolag != AdfAutolagFixed {
+		maxLag = schwertLags(len(series))
+		// Keep enough observations for the regression, as statsmodels does.
+		maxLag = min(maxLag, len(series)/2-trendTerms-1)
+	}
+	if maxLag < 0 || len(series)-maxLag-1 <= maxLag+1+trendTerms {
+		return result, fmt.Errorf("series of %d observations is too short for an ADF test with %d lags", len(series), maxLag)
+	}
+
+	usedLag := maxLag
+	if options.Autolag != AdfAutolagFixed {
+		// Every candidate is fitted on the sample of the largest lag so the criteria are comparable.
+		best := math.Inf(1)
+		for lag := 0; lag <= maxLag; lag++ {
+			x, y := adfDesign(series, lag, maxLag, options.Regression)
+			_, ssr, err := leastSquares(x, y)
+			if err != nil {
+				return result, err
+			}
+			nobs, k := x.Dims()
+			ic := informationCriterion(options.Autolag, ssr[0], nobs, k)
+			if ic < best {
+				best, usedLag = ic, lag
+			}
+		}
+	}
+
+	x, y := adfDesign(series, usedLag, usedLag, options.Regression)
+	coef, ssr, err := leastSquares(x, y)
+	if err != nil {
+		return result, err
+	}
+	nobs, k := x.Dims()
+	standardError, err := coefficientStandardError(x, ssr[0]/float64(nobs-k), 0)
+	if err != nil {
+		return result, err
+	}
+
+	result = StationarityTest{
+		Test:       StationarityTestAdf,
+		Regression: options.Regression,
+		Autolag:    options.Autolag,
+		Statistic:  coef.At(0, 0) / standardError,
+		UsedLag:    usedLag,
+		Nobs:       nobs,
+	}
+	result.PValue = mackinnonPValue(result.Statistic, options.Regression)
+	result.CriticalValues = mackinnonCriticalValues(options.Regression, nobs)
+	result.Stationary = result.PValue < StationaritySignificanceLevel
+	result.FillString()
+	return
+}
+
+// KpssTest runs the KPSS test, whose null hypothesis is stationarity around a constant or a trend. The series
+// is taken as stationary unless the p-value drops below StationaritySignificanceLevel. KPSS has no regression
+// without deterministic terms, so AdfRegressionNone falls back to a constant.
+func KpssTest(series []float64, options StationarityOptions) (result StationarityTest, err error) {
+	regression := options.Regression
+	if regression != AdfRegressionConstantTrend {
+		regression = AdfRegressionConstant
+	}
+	nobs := len(series)
+	if nobs < 3 {
+		return result, fmt.Errorf("series of %d observations is too short for a KPSS test", nobs)
+	}
+
+	x := mat.NewDense(nobs, adfTrendTerms(regression), nil)
+	y := mat.NewDense(nobs, 1, series)
+	for t := 0; t < nobs; t++ {
+		x.Set(t, 0, 1)
+		if regression == AdfRegressionConstantTrend {
+			x.Set(t, 1, float64(t+1))
+		}
+	}
+	residuals, err := regressionResiduals(x, y)
+	if err != nil {
+		return result, err
+	}
+
+	lags := options.MaxLag
+	if options.Autolag != AdfAutolagFixed {
+		lags = kpssAutolag(residuals)
+	}
+	lags = min(lags, nobs-1)
+
+	var eta, partial float64
+	for _, residual := range residuals {
+		partial += residual
+		eta += partial * partial
+	}
+	eta /= float64(nobs) * float64(nobs)
+
+	result = StationarityTest{
+		Test:       StationarityTestKpss,
+		Regression: regression,
+		Statistic:  eta / longRunVariance(residuals, lags),
+		UsedLag:    lags,
+		Nobs:       nobs,
+	}
+	critical := kpssCritical[regression]
+	result.CriticalValues = CriticalValues{OnePercent: critical[3], FivePercent: critical[1], TenPercent: critical[0]}
+	result.PValue = interpolate(result.Statistic, critical, kpssPValues)
+	result.Stationary = result.PValue >= StationaritySignificanceLevel
+	result.FillString()
+	return
+}
+
+// PhillipsPerronTest runs the Phillips-Perron Z-tau test. Like ADF it tests for a unit root, but corrects the
+// Dickey-Fuller t-statistic with a Newey-West long run variance instead of adding lagged differences, so it
+// shares ADF's MacKinnon p-values and critical values.
+func PhillipsPerronTest(series []float64, options StationarityOptions) (result StationarityTest, err error) {
+	if options.Regression == "" {
+		options.Regression = AdfRegressionConstant
+	}
+	if _, known := adfCritical[options.Regression]; !known {
+		return result, fmt.Errorf("unknown Phillips-Perron regression %q", options.Regression)
+	}
+
+	lags := options.MaxLag
+	if options.Autolag != AdfAutolagFixed {
+		lags = schwertLags(len(series))
+	}
+
+	x, y := adfDesign(series, 0, 0, options.Regression)
+	nobs, k := x.Dims()
+	if nobs < k+lags {
+		return result, fmt.Errorf("series of %d observations is too short for a Phillips-Perron test with %d lags", len(series), lags)
+	}
+	coef, ssr, err := leastSquares(x, y)
+	if err != nil {
+		return result, err
+	}
+	residuals, err := regressionResiduals(x, y)
+	if err != nil {
+		return result, err
+	}
+
+	n := float64(nobs)
+	gamma0 := ssr[0] / n
+	s := math.Sqrt(ssr[0] / (n - float64(k)))
+	standardError, err := coefficientStandardError(x, s*s, 0)
+	if err != nil {
+		return result, err
+	}
+	lambda2 := longRunVariance(residuals, lags)
+	lambda := math.Sqrt(lambda2)
+	tau := coef.At(0, 0) / standardError
+
+	result = StationarityTest{
+		Test:       StationarityTestPhillipsPerron,
+		Regression: options.Regression,
+		Statistic:  math.Sqrt(gamma0/lambda2)*tau - 0.5*((lambda2-gamma0)/lambda)*(n*standardError/s),
+		UsedLag:    lags,
+		Nobs:       nobs,
+	}
+	result.PValue = mackinnonPValue(result.Statistic, options.Regression)
+	result.CriticalValues = mackinnonCriticalValues(options.Regression, nobs)
+	result.Stationary = result.PValue < StationaritySignificanceLevel
+	result.FillString()
+	return
+}
+
+func schwertLags(nobs int) int {
+	return int(math.Ceil(12 * math.Pow(float64(nobs)/100, 0.25)))
+}
+
+// adfDesign regresses the difference at t on the level at t-1, lag lagged differences and the deterministic
+// terms, starting late enough that sampleLag lags would fit.
+func adfDesign(series []float64, lag, sampleLag int, regression string) (x, y *mat.Dense) {
+	diff := difference(series)
+	nobs := len(diff) - sampleLag
+	cols := 1 + lag + adfTrendTerms(regression)
+	x = mat.NewDense(nobs, cols, nil)
+	y = mat.NewDense(nobs, 1, nil)
+	for row := 0; row < nobs; row++ {
+		t := sampleLag + row
+		y.Set(row, 0, diff[t])
+		x.Set(row, 0, series[t])
+		for l := 1; l <= lag; l++ {
+			x.Set(row, l, diff[t-l])
+		}
+		switch regression {
+		case AdfRegressionConstant:
+			x.Set(row, 1+lag, 1)
+		case AdfRegressionConstantTrend:
+			x.Set(row, 1+lag, 1)
+			x.Set(row, 2+lag, float64(row+1))
+		}
+	}
+	return
+}
+
+func adfTrendTerms(regression string) int {
+	switch regression {
+	case AdfRegressionConstant:
+		return 1
+	case AdfRegressionConstantTrend:
+		return 2
+	}
+	return 0
+}
+
+// informationCriterion is statsmodels' OLS AIC or BIC for k regressors.
+func informationCriterion(criterion string, ssr float64, nobs, k int) float64 {
+	n := float64(nobs)
+	llf := -n / 2 * (math.Log(2*math.Pi) + math.Log(ssr/n) + 1)
+	if criterion == AdfAutolagBIC {
+		return -2*llf + float64(k)*math.Log(n)
+	}
+	return -2*llf + 2*float64(k)
+}
+
+// coefficientStandardError is sqrt(s^2 * (X'X)^-1) at the given coefficient.
+func coefficientStandardError(x *mat.Dense, variance float64, index int) (float64, error) {
+	_, cols := x.Dims()
+	var xtx mat.SymDense
+	xtx.SymOuterK(1, x.T())
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(&xtx); !ok {
+		return 0, ErrSingularDesignMatrix
+	}
+	unit := mat.NewVecDense(cols, nil)
+	unit.SetVec(index, 1)
+	var column mat.VecDense
+	if err := chol.SolveVecTo(&column, unit); err != nil {
+		return 0, ErrSingularDesignMatrix
+	}
+	return math.Sqrt(variance * column.AtVec(index)), nil
+}
+
+func regressionResiduals(x, y *mat.Dense) ([]float64, error) {
+	coef, _, err := leastSquares(x, y)
+	if err != nil {
+		return nil, err
+	}
+	var fitted mat.Dense
+	fitted.Mul(x, coef)
+	fitted.Sub(y, &fitted)
+	return mat.Col(nil, 0, &fitted), nil
+}
+
+// longRunVariance is the Newey-West estimate with Bartlett weights 1 - i/(lags+1).
+func longRunVariance(residuals []float64, lags int) float64 {
+	n := len(residuals)
+	variance := autocovariance(residuals, 0)
+	for i := 1; i <= lags; i++ {
+		variance += 2 * autocovariance(residuals, i) * (1 - float64(i)/float64(lags+1))
+	}
+	return variance / float64(n)
+}
+
+// autocovariance is the unscaled sum of residuals[t] * residuals[t-lag].
+func autocovariance(residuals []float64, lag int) (sum float64) {
+	for t := lag; t < len(residuals); t++ {
+		sum += residuals[t] * residuals[t-lag]
+	}
+	return
+}
+
+// kpssAutolag is the data dependent bandwidth of Hobijn et al. (1998).
+func kpssAutolag(residuals []float64) int {
+	n := float64(len(residuals))
+	covlags := int(math.Pow(n, 2.0/9.0))
+	s0 := autocovariance(residuals, 0) / n
+	var s1 float64
+	for i := 1; i <= covlags; i++ {
+		product := autocovariance(residuals, i) / (n / 2)
+		s0 += product
+		s1 += float64(i) * product
+	}
+	sHat := s1 / s0
+	gammaHat := 1.1447 * math.Pow(sHat*sHat, 1.0/3.0)
+	return int(gammaHat * math.Pow(n, 1.0/3.0))
+}
+
+// interpolate is numpy's interp, clamped to the first and last value outside the table.
+func interpolate(x float64, xs, ys []float64) float64 {
+	if x <= xs[0] {
+		return ys[0]
+	}
+	for i := 1; i < len(xs); i++ {
+		if x <= xs[i] {
+			return ys[i-1] + (ys[i]-ys[i-1])*(x-xs[i-1])/(xs[i]-xs[i-1])
+		}
+	}
+	return ys[len(ys)-1]
+}
+
+// Kwiatkowski et al. (1992) critical values at 10, 5, 2.5 and 1 percent.
+var (
+	kpssCritical = map[string][]float64{
+		AdfRegressionConstant:      {0.347, 0.463, 0.574, 0.739},
+		AdfRegressionConstantTrend: {0.119, 0.146, 0.176, 0.216},
+	}
+	kpssPValues = []float64{0.10, 0.05, 0.025, 0.01}
+)
+
+// MacKinnon (1994) response surface for the p-value of a single series, as tabulated in statsmodels.
+// Below tauStar the small p polynomial applies, above it the large p one.
+var (
+	adfTauMax   = map[string]float64{AdfRegressionNone: math.Inf(1), AdfRegressionConstant: 2.74, AdfRegressionConstantTrend: 0.7}
+	adfTauMin   = map[string]float64{AdfRegressionNone: -19.04, AdfRegressionConstant: -18.83, AdfRegressionConstantTrend: -16.18}
+	adfTauStar  = map[string]float64{AdfRegressionNone: -1.04, AdfRegressionConstant: -1.61, AdfRegressionConstantTrend: -2.89}
+	adfSmallP   = map[string][]float64{AdfRegressionNone: {0.6344, 1.2378, 3.2496e-2}, AdfRegressionConstant: {2.1659, 1.4412, 3.8269e-2}, AdfRegressionConstantTrend: {3.2512, 1.6047, 4.9588e-2}}
+	adfLargeP   = map[string][]float64{AdfRegressionNone: {0.4797, 9.3557e-1, -0.6999e-1, 3.3066e-2}, AdfRegressionConstant: {1.7339, 9.3202e-1, -1.2745e-1, -1.0368e-2}, AdfRegressionConstantTrend: {2.5261, 6.1654e-1, -3.7956e-1, -6.0285e-2}}
+	adfCritical = map[string][3][4]float64{
+		AdfRegressionNone: {
+			{-2.56574, -2.2358, -3.627, 0},
+			{-1.94100, -0.2686, -3.365, 31.223},
+			{-1.61682, 0.2656, -2.714, 25.364},
+		},
+		AdfRegressionConstant: {
+			{-3.43035, -6.5393, -16.786, -79.433},
+			{-2.86154, -2.8903, -4.234, -40.040},
+			{-2.56677, -1.5384, -2.809, 0},
+		},
+		AdfRegressionConstantTrend: {
+			{-3.95877, -9.0531, -28.428, -134.155},
+			{-3.41049, -4.3904, -9.036, -45.374},
+			{-3.12705, -2.5856, -3.925, -22.380},
+		},
+	}
+)
+
+func mackinnonPValue(statistic float64, regression string) float64 {
+	if statistic > adfTauMax[regression] {
+		return 1
+	}
+	if statistic < adfTauMin[regression] {
+		return 0
+	}
+
+	coefficients := adfLargeP[regression]
+	if statistic <= adfTauStar[regression] {
+		coefficients = adfSmallP[regression]
+	}
+	var value float64
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		value = value*statistic + coefficients[i]
+	}
+	return distuv.UnitNormal.CDF(value)
+}
+
+// mackinnonCriticalValues evaluates MacKinnon (2010) b0 + b1/T + b2/T^2 + b3/T^3 at 1, 5 and 10 percent.
+func mackinnonCriticalValues(regression string, nobs int) (critical CriticalValues) {
+	values := [3]float64{}
+	t := float64(nobs)
+	for i, b := range adfCritical[regression] {
+		values[i] = b[0] + b[1]/t + b[2]/(t*t) + b[3]/(t*t*t)
+	}
+	return CriticalValues{OnePercent: values[0], FivePercent: values[1], TenPercent: values[2]}
+}
+
+func (r *StationarityTest) FillString() {
+	r.StatisticStr = strconv.FormatFloat(r.Statistic, 'f', 4, 64)
+	r.PValueStr = strconv.FormatFloat(r.PValue, 'f', 4, 64)
+	r.CriticalValues.FillString()
+}
+
+func (c *CriticalValues) FillString() {
+	c.OnePercentStr = strconv.FormatFloat(c.OnePercent, 'f', 4, 64)
+	c.FivePercentStr = strconv.FormatFloat(c.FivePercent, 'f', 4, 64)
+	c.TenPercentStr = strconv.FormatFloat(c.TenPercent, 'f', 4, 64)
+}
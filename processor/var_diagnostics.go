@@ -0,0 +1,216 @@
+package processor
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const (
+	DiagnosticsSignificanceLevel = 0.05
+	DefaultPortmanteauLags       = 10
+)
+
+// Diagnostics checks the fitted VAR the way statsmodels' test_whiteness, test_normality and is_stable do.
+func (m *VarModel) Diagnostics() (diagnostics VarDiagnostics, err error) {
+	if len(m.Residuals) == 0 {
+		return diagnostics, errors.New("the VAR model has no residuals")
+	}
+
+	lags := DefaultPortmanteauLags
+	if lags <= m.LagOrder {
+		lags = m.LagOrder + 1
+	}
+	if diagnostics.Portmanteau, err = m.PortmanteauTest(lags); err != nil {
+		return diagnostics, err
+	}
+	if diagnostics.Normality, err = m.NormalityTest(); err != nil {
+		return diagnostics, err
+	}
+	if diagnostics.Stability, err = m.StabilityCheck(); err != nil {
+		return diagnostics, err
+	}
+	return
+}
+
+// PortmanteauTest is the multivariate test of no residual autocorrelation up to lags, Q = T sum tr(C_i' C_0^-1 C_i C_0^-1)
+// with C_i the residual autocovariances. The adjusted statistic weighs lag i by T/(T-i) like Ljung-Box. Both are
+// chi-square with K^2 (lags - LagOrder) degrees of freedom.
+func (m *VarModel) PortmanteauTest(lags int) (test PortmanteauTest, err error) {
+	k := len(m.Coefficients)
+	nobs := len(m.Residuals)
+	test = PortmanteauTest{
+		Lags: lags,
+		Df:   k * k * (lags - m.LagOrder),
+	}
+	if test.Df <= 0 || lags >= nobs {
+		return test, errors.New("portmanteau lags must be above the lag order and below the observations")
+	}
+
+	centered := centeredResiduals(m.Residuals)
+	covariances := make([]*mat.Dense, lags+1)
+	for lag := 0; lag <= lags; lag++ {
+		covariances[lag] = mat.NewDense(k, k, nil)
+		var product mat.Dense
+		product.Mul(centered.Slice(lag, nobs, 0, k).T(), centered.Slice(0, nobs-lag, 0, k))
+		covariances[lag].Scale(1/float64(nobs), &product)
+	}
+
+	var covarianceInverse mat.Dense
+	if err := covarianceInverse.Inverse(covariances[0]); err != nil {
+		return test, errors.New("residual covariance is singular")
+	}
+
+	for lag := 1; lag <= lags; lag++ {
+		var left, right, product mat.Dense
+		left.Mul(covariances[lag].T(), &covarianceInverse)
+		right.Mul(covariances[lag], &covarianceInverse)
+		product.Mul(&left, &right)
+		term := mat.Trace(&product)
+		test.Statistic += term
+		test.AdjustedStatistic += term / float64(nobs-lag)
+	}
+	test.Statistic *= float64(nobs)
+	test.AdjustedStatistic *= float64(nobs * nobs)
+
+	chiSquared := distuv.ChiSquared{K: float64(test.Df)}
+	test.PValue = chiSquared.Survival(test.Statistic)
+	test.AdjustedPValue = chiSquared.Survival(test.AdjustedStatistic)
+	test.White = test.AdjustedPValue >= DiagnosticsSignificanceLevel
+	test.FillString()
+	return
+}
+
+// NormalityTest is Lutkepohl's multivariate Jarque-Bera test on the residuals standardized with the Cholesky factor of
+// their covariance, chi-square with 2K degrees of freedom. Every variable also gets the univariate Jarque-Bera test.
+func (m *VarModel) NormalityTest() (test NormalityTest, err error) {
+	k := len(m.Coefficients)
+	nobs := len(m.Residuals)
+	centered := centeredResiduals(m.Residuals)
+
+	var covariance mat.Dense
+	covariance.Mul(centered.T(), centered)
+	covariance.Scale(1/float64(nobs), &covariance)
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(symmetric(&covariance)); !ok {
+		return test, errors.New("residual covariance is not positive definite")
+	}
+	var l mat.TriDense
+	chol.LTo(&l)
+	var standardized mat.Dense
+	if err := standardized.Solve(&l, centered.T()); err != nil {
+		return test, errors.New("residual covariance is singular")
+	}
+
+	n := float64(nobs)
+	for i := 0; i < k; i++ {
+		skewness, kurtosis := moments(mat.Row(nil, i, &standardized))
+		test.SkewnessStatistic += n * skewness * skewness / 6
+		test.KurtosisStatistic += n * (kurtosis - 3) * (kurtosis - 3) / 24
+	}
+	test.Df = 2 * k
+	test.Statistic = test.SkewnessStatistic + test.KurtosisStatistic
+	test.PValue = distuv.ChiSquared{K: float64(test.Df)}.Survival(test.Statistic)
+	test.Normal = test.PValue >= DiagnosticsSignificanceLevel
+
+	for v := 0; v < k; v++ {
+		column := mat.Col(nil, v, centered)
+		std := math.Sqrt(mat.Dot(mat.NewVecDense(nobs, column), mat.NewVecDense(nobs, column)) / n)
+		for t := range column {
+			column[t] /= std
+		}
+		skewness, kurtosis := moments(column)
+		variable := VariableNormality{
+			Variable:   m.Variables[v],
+			Skewness:   skewness,
+			Kurtosis:   kurtosis,
+			JarqueBera: n / 6 * (skewness*skewness + (kurtosis-3)*(kurtosis-3)/4),
+		}
+		variable.PValue = distuv.ChiSquared{K: 2}.Survival(variable.JarqueBera)
+		variable.Normal = variable.PValue >= DiagnosticsSignificanceLevel
+		variable.FillString()
+		test.Variables = append(test.Variables, variable)
+	}
+	test.FillString()
+	return
+}
+
+// StabilityCheck computes the moduli of the companion matrix eigenvalues, the VAR is stable when all lie inside the unit circle.
+func (m *VarModel) StabilityCheck() (check StabilityCheck, err error) {
+	k := len(m.Coefficients)
+	size := k * m.LagOrder
+	companion := mat.NewDense(size, size, nil)
+	for i, coef := range m.Coefficients {
+		for j := 0; j < size; j++ {
+			companion.Set(i, j, coef[1+j])
+		}
+	}
+	for i := k; i < size; i++ {
+		companion.Set(i, i-k, 1)
+	}
+
+	var eigen mat.Eigen
+	if ok := eigen.Factorize(companion, mat.EigenNone); !ok {
+		return check, errors.New("eigen decomposition of the companion matrix failed")
+	}
+	for _, value := range eigen.Values(nil) {
+		check.Moduli = append(check.Moduli, cmplx.Abs(value))
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(check.Moduli)))
+
+	check.MaxModulus = check.Moduli[0]
+	check.Stable = check.MaxModulus < 1
+	check.MaxModulusStr = strconv.FormatFloat(check.MaxModulus, 'f', 4, 64)
+	return
+}
+
+func centeredResiduals(residuals [][]float64) *mat.Dense {
+	nobs, k := len(residuals), len(residuals[0])
+	centered := mat.NewDense(nobs, k, flatten(residuals))
+	for v := 0; v < k; v++ {
+		column := mat.Col(nil, v, centered)
+		var mean float64
+		for _, value := range column {
+			mean += value / float64(nobs)
+		}
+		for t := range column {
+			centered.Set(t, v, column[t]-mean)
+		}
+	}
+	return centered
+}
+
+// moments returns the third and fourth moments of an already standardized series.
+func moments(standardized []float64) (skewness, kurtosis float64) {
+	n := float64(len(standardized))
+	for _, value := range standardized {
+		skewness += value * value * value / n
+		kurtosis += value * value * value * value / n
+	}
+	return
+}
+
+func (t *PortmanteauTest) FillString() {
+	t.StatisticStr = strconv.FormatFloat(t.Statistic, 'f', 4, 64)
+	t.PValueStr = strconv.FormatFloat(t.PValue, 'f', 4, 64)
+	t.AdjustedStatisticStr = strconv.FormatFloat(t.AdjustedStatistic, 'f', 4, 64)
+	t.AdjustedPValueStr = strconv.FormatFloat(t.AdjustedPValue, 'f', 4, 64)
+}
+
+func (t *NormalityTest) FillString() {
+	t.StatisticStr = strconv.FormatFloat(t.Statistic, 'f', 4, 64)
+	t.PValueStr = strconv.FormatFloat(t.PValue, 'f', 4, 64)
+}
+
+func (v *VariableNormality) FillString() {
+	v.SkewnessStr = strconv.FormatFloat(v.Skewness, 'f', 4, 64)
+	v.KurtosisStr = strconv.FormatFloat(v.Kurtosis, 'f', 4, 64)
+	v.JarqueBeraStr = strconv.FormatFloat(v.JarqueBera, 'f', 4, 64)
+	v.PValueStr = strconv.FormatFloat(v.PValue, 'f', 4, 64)
+}
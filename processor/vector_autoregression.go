@@ -0,0 +1,403 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const (
+	LagCriterionAIC  = "aic"
+	LagCriterionBIC  = "bic"
+	LagCriterionHQIC = "hqic"
+	LagCriterionFPE  = "fpe"
+
+	DefaultMaxLagOrder = 10
+)
+
+var varVariables = []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M_MAX", "T2M_MIN"}
+
+// FitVectorAutoregression fits a VAR of the given order with an intercept to the six weather variables.
+func (w *Weathers) FitVectorAutoregression(lagOrder int) (VarModel, error) {
+	return fitVarModel(w.varMatrix(), nil, lagOrder, 0)
+}
+
+// SelectLagOrder fits lags 1 to maxLag on the same sample and picks the lag minimizing the criterion.
+// With an empty criterion the table is still filled but nothing is selected.
+func (w *Weathers) SelectLagOrder(maxLag int, criterion string) (selection LagSelection, err error) {
+	selection = LagSelection{
+		Criterion: criterion,
+		MaxLag:    maxLag,
+	}
+
+	data := w.varMatrix()
+	best := map[string]float64{}
+	for lag := 1; lag <= maxLag; lag++ {
+		model, err := fitVarModel(data, nil, lag, maxLag-lag)
+		if err != nil {
+			return selection, fmt.Errorf("fitting lag %d: %w", lag, err)
+		}
+
+		criteria, err := model.InformationCriteria()
+		if err != nil {
+			return selection, fmt.Errorf("lag %d: %w", lag, err)
+		}
+		selection.Criteria = append(selection.Criteria, criteria)
+		for name, value := range map[string]float64{LagCriterionAIC: criteria.AIC, LagCriterionBIC: criteria.BIC, LagCriterionHQIC: criteria.HQIC, LagCriterionFPE: criteria.FPE} {
+			if current, exists := best[name]; !exists || value < current {
+				best[name] = value
+				selection.setBest(name, lag)
+			}
+		}
+	}
+
+	switch criterion {
+	case LagCriterionAIC:
+		selection.Selected = selection.BestAIC
+	case LagCriterionBIC:
+		selection.Selected = selection.BestBIC
+	case LagCriterionHQIC:
+		selection.Selected = selection.BestHQIC
+	case LagCriterionFPE:
+		selection.Selected = selection.BestFPE
+	}
+	selection.FillString()
+	return
+}
+
+func (w *Weathers) varMatrix() [][]float64 {
+	data := make([][]float64, len(w.Items))
+	for i, d := range w.Items {
+		data[i] = []float64{d.WindSpeed, d.RelHumidity, d.Precipitation, d.TempAverage, d.TempMax, d.TempMin}
+	}
+	return data
+}
+
+// fitVarModel regresses every row from skip+lagOrder on against a constant, the lagOrder rows before it and the
+// exogenous row of the same day when exogenous is not nil. Skipping rows lets models of different orders share one
+// estimation sample.
+func fitVarModel(data, exogenous [][]float64, lagOrder, skip int) (model VarModel, err error) {
+	if lagOrder < 1 || len(data) == 0 {
+		return model, fmt.Errorf("lag order %d is not valid for %d observations", lagOrder, len(data))
+	}
+
+	numOfVariables := len(data[0])
+	numOfExogenous := 0
+	if len(exogenous) > 0 {
+		numOfExogenous = len(exogenous[0])
+	}
+	nobs := len(data) - skip - lagOrder
+	regressors := 1 + numOfVariables*lagOrder + numOfExogenous
+	if nobs <= regressors {
+		return model, ErrSingularDesignMatrix
+	}
+
+	response := mat.NewDense(nobs, numOfVariables, nil)
+	design := mat.NewDense(nobs, regressors, nil)
+	for t := 0; t < nobs; t++ {
+		row := skip + lagOrder + t
+		response.SetRow(t, data[row])
+		varRegressors(design.RawRowView(t), data, exogenous, lagOrder, row)
+	}
+
+	// The normal equations keep the repeated refits in the evaluations cheap, Cholesky flags a singular design.
+	var xTx mat.SymDense
+	xTx.SymOuterK(1, design.T())
+	var chol mat.Cholesky
+	if ok := chol.Factorize(&xTx); !ok {
+		return model, ErrSingularDesignMatrix
+	}
+
+	var xTy, coef mat.Dense
+	xTy.Mul(design.T(), response)
+	if err := chol.SolveTo(&coef, &xTy); err != nil {
+		return model, ErrSingularDesignMatrix
+	}
+
+	var residuals mat.Dense
+	residuals.Mul(design, &coef)
+	residuals.Sub(response, &residuals)
+
+	var sse mat.Dense
+	sse.Mul(residuals.T(), &residuals)
+
+	model = VarModel{
+		LagOrder:     lagOrder,
+		Variables:    varVariables[:numOfVariables],
+		Nobs:         nobs,
+		Coefficients: make([][]float64, numOfVariables),
+		SigmaU:       make([][]float64, numOfVariables),
+		Residuals:    make([][]float64, nobs),
+	}
+	dfResid := float64(nobs - regressors)
+	for i := 0; i < numOfVariables; i++ {
+		model.Coefficients[i] = mat.Col(nil, i, &coef)
+		model.SigmaU[i] = make([]float64, numOfVariables)
+		for j := 0; j < numOfVariables; j++ {
+			model.SigmaU[i][j] = sse.At(i, j) / dfResid
+		}
+	}
+	for t := 0; t < nobs; t++ {
+		model.Residuals[t] = residuals.RawRowView(t)
+	}
+	return
+}
+
+// varRegressors fills regressors with the constant, the lagOrder rows before row and the exogenous row of row.
+func varRegressors(regressors []float64, data, exogenous [][]float64, lagOrder, row int) {
+	regressors[0] = 1
+	for lag := 1; lag <= lagOrder; lag++ {
+		copy(regressors[1+(lag-1)*len(data[row]):], data[row-lag])
+	}
+	if len(exogenous) > 0 {
+		copy(regressors[1+len(data[row])*lagOrder:], exogenous[row])
+	}
+}
+
+// Forecast predicts the row following history, which needs at least LagOrder rows and, with a trend, has to start
+// at the first row of the fitted data. Exogenous regressors are left out, see ForecastExogenous.
+func (m *VarModel) Forecast(history [][]float64) []float64 {
+	return m.forecastRow(history, len(history))
+}
+
+// forecastRow is Forecast for row t of the fitted data, history ending at the row before it.
+func (m *VarModel) forecastRow(history [][]float64, t int) []float64 {
+	forecast := make([]float64, len(m.Coefficients))
+	for i, coef := range m.Coefficients {
+		forecast[i] = coef[0]
+		if m.Trend != nil {
+			forecast[i] += m.Trend[i] * float64(t)
+		}
+		for lag := 1; lag <= m.LagOrder; lag++ {
+			previous := history[len(history)-lag]
+			for v, value := range previous {
+				forecast[i] += coef[1+(lag-1)*len(previous)+v] * value
+			}
+		}
+	}
+	return forecast
+}
+
+// InformationCriteria follows the statsmodels definitions, based on the ML residual covariance.
+func (m *VarModel) InformationCriteria() (criteria LagCriteria, err error) {
+	k := len(m.Coefficients)
+	nobs := float64(m.Nobs)
+	dfModel := float64(1 + k*m.LagOrder)
+	freeParams := float64(k) * dfModel
+
+	sigmaMle := mat.NewSymDense(k, nil)
+	for i := 0; i < k; i++ {
+		for j := i; j < k; j++ {
+			sigmaMle.SetSym(i, j, m.SigmaU[i][j]*(nobs-dfModel)/nobs)
+		}
+	}
+	var chol mat.Cholesky
+	if ok := chol.Factorize(sigmaMle); !ok {
+		return criteria, errors.New("residual covariance is not positive definite")
+	}
+	logDet := chol.LogDet()
+
+	criteria = LagCriteria{
+		Lag:  m.LagOrder,
+		Nobs: m.Nobs,
+		AIC:  logDet + 2/nobs*freeParams,
+		BIC:  logDet + math.Log(nobs)/nobs*freeParams,
+		HQIC: logDet + 2*math.Log(math.Log(nobs))/nobs*freeParams,
+		FPE:  math.Pow((nobs+dfModel)/(nobs-dfModel), float64(k)) * math.Exp(logDet),
+	}
+	return
+}
+
+func (s *LagSelection) setBest(criterion string, lag int) {
+	switch criterion {
+	case LagCriterionAIC:
+		s.BestAIC = lag
+	case LagCriterionBIC:
+		s.BestBIC = lag
+	case LagCriterionHQIC:
+		s.BestHQIC = lag
+	case LagCriterionFPE:
+		s.BestFPE = lag
+	}
+}
+
+// FillString formats the criteria table, marking the best lag of each criterion with an asterisk like statsmodels does.
+func (s *LagSelection) FillString() {
+	format := func(value float64, best bool) string {
+		str := strconv.FormatFloat(value, 'f', 4, 64)
+		if math.Abs(value) < 1e-3 && value != 0 {
+			str = strconv.FormatFloat(value, 'e', 4, 64)
+		}
+		if best {
+			str += "*"
+		}
+		return str
+	}
+
+	for i := range s.Criteria {
+		c := &s.Criteria[i]
+		c.AICStr = format(c.AIC, c.Lag == s.BestAIC)
+		c.BICStr = format(c.BIC, c.Lag == s.BestBIC)
+		c.HQICStr = format(c.HQIC, c.Lag == s.BestHQIC)
+		c.FPEStr = format(c.FPE, c.Lag == s.BestFPE)
+	}
+}
+
+// ForecastHorizon iterates the model steps days past history.
+func (m *VarModel) ForecastHorizon(history [][]float64, steps int) (forecasts [][]float64) {
+	extended := append([][]float64{}, history[len(history)-m.LagOrder:]...)
+	for h := 0; h < steps; h++ {
+		next := m.forecastRow(extended, len(history)+h)
+		forecasts = append(forecasts, next)
+		extended = append(extended, next)
+	}
+	return
+}
+
+// ForecastMSE is the forecast error covariance of steps 1 to steps, the sum of Psi_i SigmaU Psi_i' for i
+// below h, leaving out the parameter estimation uncertainty. Row v of Psi is that of the MA coefficients
+// summed integrationOrders[v] times, so the covariance is that of the forecasts integrated back to original
+// units. A nil integrationOrders gives the covariance of the modelled series.
+func (m *VarModel) ForecastMSE(steps int, integrationOrders []int) (mse []*mat.SymDense) {
+	k := len(m.Coefficients)
+	psis := m.MovingAverageCoefficients(steps)
+	for v, integrationOrder := range integrationOrders {
+		for order := 0; order < integrationOrder; order++ {
+			for i := 1; i < steps; i++ {
+				for j := 0; j < k; j++ {
+					psis[i].Set(v, j, psis[i].At(v, j)+psis[i-1].At(v, j))
+				}
+			}
+		}
+	}
+
+	sigma := mat.NewDense(k, k, flatten(m.SigmaU))
+	cumulative := mat.NewDense(k, k, nil)
+	for _, psi := range psis {
+		var term, psiSigma mat.Dense
+		psiSigma.Mul(psi, sigma)
+		term.Mul(&psiSigma, psi.T())
+		cumulative.Add(cumulative, &term)
+
+		step := mat.NewSymDense(k, nil)
+		for i := 0; i < k; i++ {
+			for j := i; j < k; j++ {
+				step.SetSym(i, j, (cumulative.At(i, j)+cumulative.At(j, i))/2)
+			}
+		}
+		mse = append(mse, step)
+	}
+	return
+}
+
+// MovingAverageCoefficients returns Phi_0 to Phi_{steps-1} of the VAR's MA representation, with Phi_0 the identity.
+func (m *VarModel) MovingAverageCoefficients(steps int) []*mat.Dense {
+	k := len(m.Coefficients)
+	lags := make([]*mat.Dense, m.LagOrder)
+	for lag := 1; lag <= m.LagOrder; lag++ {
+		lags[lag-1] = mat.NewDense(k, k, nil)
+		for i, coef := range m.Coefficients {
+			for v := 0; v < k; v++ {
+				lags[lag-1].Set(i, v, coef[1+(lag-1)*k+v])
+			}
+		}
+	}
+
+	phis := make([]*mat.Dense, steps)
+	for i := 0; i < steps; i++ {
+		phis[i] = mat.NewDense(k, k, nil)
+		if i == 0 {
+			for v := 0; v < k; v++ {
+				phis[i].Set(v, v, 1)
+			}
+			continue
+		}
+		for j := 1; j <= i && j <= m.LagOrder; j++ {
+			var term mat.Dense
+			term.Mul(phis[i-j], lags[j-1])
+			phis[i].Add(phis[i], &term)
+		}
+	}
+	return phis
+}
+
+// ForecastHorizon forecasts steps days past the last item with confidence intervals and classifies every
+// forecasted day with KNN against the observed days, giving the flood risk per day. A differenced series is
+// also forecast in original units, classified against the original days.
+func (w *Weathers) ForecastHorizon(model VarModel, steps int, confidenceLevel float64, kValue int, knn KnnOptions) (forecast Forecast) {
+	forecast = Forecast{
+		Horizon:         steps,
+		ConfidenceLevel: confidenceLevel,
+	}
+	if len(w.Items) < model.LagOrder || len(model.Coefficients) == 0 {
+		return
+	}
+
+	z := distuv.UnitNormal.Quantile(1 - (1-confidenceLevel)/2)
+	lastDate := w.Items[len(w.Items)-1].Date
+	var predictions []Weather
+	for h, values := range w.forecastPath(model, steps) {
+		prediction := weatherFromSlice(values)
+		prediction.Date = lastDate.AddDate(0, 0, h+1)
+		predictions = append(predictions, prediction)
+	}
+
+	forecast.Days = w.forecastDays(predictions, model.ForecastMSE(steps, nil), z, kValue, knn)
+	if w.HasOrigin() {
+		original := Weathers{Items: w.Diff.Origin}
+		forecast.OriginalDays = original.forecastDays(w.Integrate(predictions, len(w.Items)-1), model.ForecastMSE(steps, w.Diff.integrationOrders()), z, kValue, knn)
+	}
+	return
+}
+
+func (w *Weathers) forecastDays(predictions []Weather, mse []*mat.SymDense, z float64, kValue int, knn KnnOptions) (days []ForecastDay) {
+	index := w.knnIndex(false, knn)
+	for h, prediction := range predictions {
+		values := weatherValues(prediction)
+		lower, upper := make([]float64, len(values)), make([]float64, len(values))
+		for v := range values {
+			margin := z * math.Sqrt(mse[h].At(v, v))
+			lower[v] = values[v] - margin
+			upper[v] = values[v] + margin
+		}
+
+		day := ForecastDay{
+			Step:       h + 1,
+			Prediction: prediction,
+			Lower:      weatherFromSlice(lower),
+			Upper:      weatherFromSlice(upper),
+		}
+		day.Lower.Date, day.Upper.Date = prediction.Date, prediction.Date
+
+		_, result := index.KNearestNeighbor(kValue, day.Prediction)
+		day.FloodNeighbors = result.FloodNeighbors
+		day.FloodProbability = result.Probability
+		day.KNNResult = result
+
+		day.FillString()
+		days = append(days, day)
+	}
+	return
+}
+
+func (d *ForecastDay) FillString() {
+	d.Prediction.FillString()
+	d.Lower.FillString()
+	d.Upper.FillString()
+	d.FloodProbabilityStr = strconv.FormatFloat(d.FloodProbability*100, 'f', 0, 64) + "%"
+}
+
+func weatherFromSlice(values []float64) Weather {
+	return Weather{
+		WindSpeed:     values[0],
+		RelHumidity:   values[1],
+		Precipitation: values[2],
+		TempAverage:   values[3],
+		TempMax:       values[4],
+		TempMin:       values[5],
+	}
+}
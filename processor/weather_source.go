@@ -0,0 +1,517 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"skripsi/constant"
+	"skripsi/database"
+	"skripsi/helper"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WeatherSource supplies the daily NASA POWER parameters for a location and date range.
+type WeatherSource interface {
+	Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error)
+}
+
+type WeatherQuery struct {
+	City      string    `json:"city"`
+	Latitude  string    `json:"latitude"`
+	Longitude string    `json:"longitude"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+const (
+	WeatherSourceNasa      = "nasa"
+	WeatherSourceDirectory = "directory"
+	WeatherSourceReplay    = "replay"
+	WeatherSourceRecord    = "record"
+	WeatherSourcePostgres  = "postgres"
+)
+
+var ErrWeatherFixtureNotFound = errors.New("no recorded weather fixture for query")
+
+// NewWeatherSource picks the implementation named by WEATHER_SOURCE, reading files from WEATHER_SOURCE_DIR.
+// Sources that reach NASA POWER go through the cache unless WEATHER_CACHE is false.
+func NewWeatherSource(l helper.LoggerHelper, cache helper.CacheHelper, db database.Database) WeatherSource {
+	kind := strings.ToLower(strings.TrimSpace(os.Getenv("WEATHER_SOURCE")))
+	dir := os.Getenv("WEATHER_SOURCE_DIR")
+	if dir == "" {
+		dir = "tmp/weather"
+	}
+
+	var live WeatherSource = NewNasaPowerWeatherSource()
+	if strings.ToLower(strings.TrimSpace(os.Getenv("WEATHER_CACHE"))) != "false" {
+		live = NewCachedWeatherSource(live, cache)
+	}
+
+	switch kind {
+	case "", WeatherSourceNasa:
+		return live
+	case WeatherSourceDirectory:
+		return NewDirectoryWeatherSource(dir)
+	case WeatherSourceReplay:
+		return NewReplayWeatherSource(dir, nil)
+	case WeatherSourceRecord:
+		return NewReplayWeatherSource(dir, live)
+	case WeatherSourcePostgres:
+		if database.DataStore() != database.DataStorePostgres {
+			l.LogAndExit(2, "WEATHER_SOURCE postgres needs DATA_STORE postgres")
+		}
+		return NewPostgresWeatherSource(database.NewWeatherRepository(db.PostgresDatabase.GetPool()), live)
+	}
+
+	l.LogAndExit(2, "Unknown WEATHER_SOURCE %q, expected one of nasa, directory, replay, record, postgres", kind)
+	return nil
+}
+
+func (q WeatherQuery) Key() string {
+	return fmt.Sprintf("%s&%s&%s&%s", q.Latitude, q.Longitude, q.StartDate.Format(DateHyphenYMD), q.EndDate.Format(DateHyphenYMD))
+}
+
+// Contains reports whether the row's date falls inside the query range, ignoring time zones.
+func (q WeatherQuery) Contains(n Nasa) bool {
+	date := n.Date.Format(DateHyphenYMD)
+	return date >= q.StartDate.Format(DateHyphenYMD) && date <= q.EndDate.Format(DateHyphenYMD)
+}
+
+// NasaPowerWeatherSource fetches live data from the NASA POWER daily point API.
+type NasaPowerWeatherSource struct {
+	BaseURL string
+	Params  string
+	Client  *http.Client
+}
+
+func NewNasaPowerWeatherSource() WeatherSource {
+	return &NasaPowerWeatherSource{
+		BaseURL: constant.NasaPowerAPIBaseURL,
+		Params:  constant.NasaPowerAPIParams,
+		Client:  http.DefaultClient,
+	}
+}
+
+func (s *NasaPowerWeatherSource) Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error) {
+	url := fmt.Sprintf("%s?start=%s&end=%s&latitude=%s&longitude=%s&%s", s.BaseURL, query.StartDate.Format("20060102"), query.EndDate.Format("20060102"), query.Latitude, query.Longitude, s.Params)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return parseNasaResponse(resp.Body)
+}
+
+// DirectoryWeatherSource reads archived series from <lat>_<lon> or <city> files, as NASA POWER CSV or a JSON array of rows.
+type DirectoryWeatherSource struct {
+	Dir string
+}
+
+func NewDirectoryWeatherSource(dir string) WeatherSource {
+	return &DirectoryWeatherSource{
+		Dir: dir,
+	}
+}
+
+func (s *DirectoryWeatherSource) Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error) {
+	names := []string{
+		fmt.Sprintf("%s_%s", query.Latitude, query.Longitude),
+		strings.ReplaceAll(strings.ToLower(query.City), " ", "_"),
+	}
+
+	for _, name := range names {
+		for _, ext := range []string{".csv", ".json"} {
+			path := filepath.Join(s.Dir, name+ext)
+			items, err := readNasaFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+
+			var filtered []Nasa
+			for _, item := range items {
+				if query.Contains(item) {
+					filtered = append(filtered, item)
+				}
+			}
+			if len(filtered) == 0 {
+				return nil, fmt.Errorf("%s has no data between %s and %s", path, query.StartDate.Format(DateHyphenYMD), query.EndDate.Format(DateHyphenYMD))
+			}
+			return filtered, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no weather file for %s in %s", query.City, s.Dir)
+}
+
+// ReplayWeatherSource serves fixtures recorded per query; with an upstream source it records misses instead of failing.
+type ReplayWeatherSource struct {
+	Dir      string
+	Upstream WeatherSource
+}
+
+type weatherFixture struct {
+	Query WeatherQuery `json:"query"`
+	Items []Nasa       `json:"items"`
+}
+
+func NewReplayWeatherSource(dir string, upstream WeatherSource) WeatherSource {
+	return &ReplayWeatherSource{
+		Dir:      dir,
+		Upstream: upstream,
+	}
+}
+
+func (s *ReplayWeatherSource) Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error) {
+	path := s.fixturePath(query)
+	content, err := os.ReadFile(path)
+	if err == nil {
+		var fixture weatherFixture
+		if err := json.Unmarshal(content, &fixture); err != nil {
+			return nil, fmt.Errorf("reading fixture %s: %w", path, err)
+		}
+		return fixture.Items, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if s.Upstream == nil {
+		return nil, fmt.Errorf("%w %s", ErrWeatherFixtureNotFound, query.Key())
+	}
+
+	items, err := s.Upstream.Fetch(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err = json.Marshal(weatherFixture{Query: query, Items: items})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+	// Write then rename so a concurrent replay never sees a half written fixture.
+	tempPath := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *ReplayWeatherSource) fixturePath(query WeatherQuery) string {
+	return filepath.Join(s.Dir, hashKey(query.Key())+".json")
+}
+
+func readNasaFile(path string) ([]Nasa, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".json" {
+		var items []Nasa
+		if err := json.NewDecoder(file).Decode(&items); err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].FillString()
+			items[i].DateStr = items[i].Date.Format(DateHyphenYMD)
+		}
+		return items, nil
+	}
+
+	return parseNasaResponse(file)
+}
+
+// PostgresWeatherSource serves stored observations and fills the table from upstream when days of the range are missing.
+type PostgresWeatherSource struct {
+	Repository database.WeatherRepository
+	Upstream   WeatherSource
+}
+
+func NewPostgresWeatherSource(repository database.WeatherRepository, upstream WeatherSource) WeatherSource {
+	return &PostgresWeatherSource{
+		Repository: repository,
+		Upstream:   upstream,
+	}
+}
+
+func (s *PostgresWeatherSource) Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error) {
+	observations, err := s.Repository.FindObservations(ctx, query.Latitude, query.Longitude, query.StartDate, query.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	days := int(query.EndDate.Sub(query.StartDate).Hours()/24) + 1
+	if len(observations) >= days || s.Upstream == nil {
+		items := make([]Nasa, len(observations))
+		for i, o := range observations {
+			items[i] = Nasa{
+				Date:          time.Date(o.Date.Year(), o.Date.Month(), o.Date.Day(), 0, 0, 0, 0, time.Local),
+				WindSpeed:     o.WindSpeed,
+				RelHumidity:   o.RelHumidity,
+				Precipitation: o.Precipitation,
+				TempAverage:   o.TempAverage,
+				TempMax:       o.TempMax,
+				TempMin:       o.TempMin,
+			}
+			items[i].FillString()
+			items[i].DateStr = items[i].Date.Format(DateHyphenYMD)
+		}
+		return items, nil
+	}
+
+	items, err := s.Upstream.Fetch(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	observations = make([]database.WeatherObservation, len(items))
+	for i, n := range items {
+		observations[i] = database.WeatherObservation{
+			Latitude:      query.Latitude,
+			Longitude:     query.Longitude,
+			Date:          time.Date(n.Date.Year(), n.Date.Month(), n.Date.Day(), 0, 0, 0, 0, time.UTC),
+			WindSpeed:     n.WindSpeed,
+			RelHumidity:   n.RelHumidity,
+			Precipitation: n.Precipitation,
+			TempAverage:   n.TempAverage,
+			TempMax:       n.TempMax,
+			TempMin:       n.TempMin,
+			Source:        WeatherSourceNasa,
+		}
+	}
+	if err := s.Repository.SaveObservations(ctx, observations); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CachedWeatherSource keeps fetched date ranges per location in the cache and only asks upstream for the missing days.
+type CachedWeatherSource struct {
+	Upstream WeatherSource
+	Cache    helper.CacheHelper
+	Params   string
+
+	// locks holds a mutex per index key, so concurrent fetches of one location do not overwrite each other's ranges.
+	locks sync.Map
+}
+
+// cachedWeatherRange is one fetched date range of a location, stored under a key derived from its content.
+type cachedWeatherRange struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Key       string `json:"key"`
+}
+
+func NewCachedWeatherSource(upstream WeatherSource, cache helper.CacheHelper) WeatherSource {
+	return &CachedWeatherSource{
+		Upstream: upstream,
+		Cache:    cache,
+		Params:   constant.NasaPowerAPIParams,
+	}
+}
+
+func (s *CachedWeatherSource) Fetch(ctx context.Context, query WeatherQuery) ([]Nasa, error) {
+	indexKey := s.indexKey(query)
+	unlock := s.lock(indexKey)
+	defer unlock()
+	ranges := s.loadIndex(indexKey)
+
+	byDate := make(map[string]Nasa)
+	var covered []cachedWeatherRange
+	for _, r := range ranges {
+		content, found := s.Cache.Get(r.Key)
+		if !found {
+			continue
+		}
+		var items []Nasa
+		if err := json.Unmarshal(content, &items); err != nil {
+			s.Cache.Delete(r.Key)
+			continue
+		}
+		covered = append(covered, r)
+		for _, item := range items {
+			if query.Contains(item) {
+				byDate[item.Date.Format(DateHyphenYMD)] = item
+			}
+		}
+	}
+
+	for _, missing := range missingWeatherRanges(query.StartDate, query.EndDate, covered) {
+		subQuery := query
+		subQuery.StartDate = missing[0]
+		subQuery.EndDate = missing[1]
+
+		items, err := s.Upstream.Fetch(ctx, subQuery)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the days upstream returned are recorded as covered, so the days it left out are asked for again.
+		for _, run := range consecutiveDays(items) {
+			runQuery := subQuery
+			runQuery.StartDate = run[0].Date
+			runQuery.EndDate = run[len(run)-1].Date
+
+			content, err := json.Marshal(run)
+			if err != nil {
+				return nil, err
+			}
+			r := cachedWeatherRange{
+				StartDate: runQuery.StartDate.Format(DateHyphenYMD),
+				EndDate:   runQuery.EndDate.Format(DateHyphenYMD),
+				Key:       s.rangeKey(runQuery),
+			}
+			s.Cache.Set(r.Key, content)
+			covered = append(covered, r)
+		}
+
+		for _, item := range items {
+			byDate[item.Date.Format(DateHyphenYMD)] = item
+		}
+	}
+
+	s.saveIndex(indexKey, ranges, covered)
+
+	dates := make([]string, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	items := make([]Nasa, len(dates))
+	for i, date := range dates {
+		items[i] = byDate[date]
+	}
+	return items, nil
+}
+
+func (s *CachedWeatherSource) lock(key string) (unlock func()) {
+	mutex, _ := s.locks.LoadOrStore(key, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
+
+func (s *CachedWeatherSource) indexKey(query WeatherQuery) string {
+	return "weather-index:" + hashKey(query.Latitude, query.Longitude, s.Params)
+}
+
+func (s *CachedWeatherSource) rangeKey(query WeatherQuery) string {
+	return "weather-range:" + hashKey(query.Latitude, query.Longitude, s.Params, query.StartDate.Format(DateHyphenYMD), query.EndDate.Format(DateHyphenYMD))
+}
+
+func (s *CachedWeatherSource) loadIndex(key string) (ranges []cachedWeatherRange) {
+	content, found := s.Cache.Get(key)
+	if !found {
+		return nil
+	}
+	if err := json.Unmarshal(content, &ranges); err != nil {
+		s.Cache.Delete(key)
+		return nil
+	}
+	return
+}
+
+// saveIndex keeps every range known before this fetch plus the ones still backed by a cache entry.
+func (s *CachedWeatherSource) saveIndex(key string, previous, covered []cachedWeatherRange) {
+	seen := make(map[string]bool)
+	var ranges []cachedWeatherRange
+	for _, r := range covered {
+		if !seen[r.Key] {
+			seen[r.Key] = true
+			ranges = append(ranges, r)
+		}
+	}
+	for _, r := range previous {
+		if seen[r.Key] {
+			continue
+		}
+		if _, found := s.Cache.Get(r.Key); found {
+			seen[r.Key] = true
+			ranges = append(ranges, r)
+		}
+	}
+
+	content, err := json.Marshal(ranges)
+	if err != nil {
+		return
+	}
+	s.Cache.Set(key, content)
+}
+
+// missingWeatherRanges returns the inclusive day ranges between start and end that no cached range covers.
+func missingWeatherRanges(start, end time.Time, covered []cachedWeatherRange) (missing [][2]time.Time) {
+	endDate := end.Format(DateHyphenYMD)
+
+	var gapStart time.Time
+	inGap := false
+	for day := start; day.Format(DateHyphenYMD) <= endDate; day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateHyphenYMD)
+		isCovered := false
+		for _, r := range covered {
+			if date >= r.StartDate && date <= r.EndDate {
+				isCovered = true
+				break
+			}
+		}
+
+		if !isCovered && !inGap {
+			gapStart = day
+			inGap = true
+		}
+		if isCovered && inGap {
+			missing = append(missing, [2]time.Time{gapStart, day.AddDate(0, 0, -1)})
+			inGap = false
+		}
+	}
+	if inGap {
+		missing = append(missing, [2]time.Time{gapStart, end})
+	}
+	return
+}
+
+// consecutiveDays splits items, in date order, into runs without a missing day.
+func consecutiveDays(items []Nasa) (runs [][]Nasa) {
+	start := 0
+	for i := 1; i <= len(items); i++ {
+		if i < len(items) && items[i].Date.Format(DateHyphenYMD) == items[i-1].Date.AddDate(0, 0, 1).Format(DateHyphenYMD) {
+			continue
+		}
+		runs = append(runs, items[start:i])
+		start = i
+	}
+	return
+}
+
+func hashKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "&")))
+	return hex.EncodeToString(sum[:])
+}
@@ -30,17 +30,21 @@ type WebProcessor interface {
 }
 
 type WebProcessorImpl struct {
-	logger helper.LoggerHelper
+	logger     helper.LoggerHelper
+	prediction PredictionProcessor
 }
 
-func NewWebProcessor(l helper.LoggerHelper) WebProcessor {
+func NewWebProcessor(l helper.LoggerHelper, prediction PredictionProcessor) WebProcessor {
 	return &WebProcessorImpl{
-		logger: l,
+		logger:     l,
+		prediction: prediction,
 	}
 }
 
 const (
 	DateHyphenYMD = "2006-01-02"
+	DateSlashDMY  = "02/01/2006"
+	DateSlashYMD  = "2006/01/02"
 	FlagV2        = false
 	MainPage      = "mainv2"
 )
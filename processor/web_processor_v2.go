@@ -2,6 +2,7 @@ package processor
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -10,9 +11,7 @@ import (
 	"math"
 	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"skripsi/constant"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,346 +20,145 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/rand"
-	"gonum.org/v1/gonum/mat"
 )
 
 func (p *WebProcessorImpl) HandleFloodPredictionRequestV2(c echo.Context) error {
-	var lagOrder int
-
-	startDateLimit := time.Date(2007, 12, 31, 0, 0, 0, 0, time.Local)
-	endDateLimit := time.Date(2024, 10, 1, 0, 0, 0, 0, time.Local)
-	p.logger.LogAndContinue("Start Processing Request")
-	start := time.Now()
-	cities := map[string]string{
-		"jakarta barat":   "-6.1674&106.7637",
-		"jakarta timur":   "-6.2250&106.9004",
-		"jakarta pusat":   "-6.1805&106.8284",
-		"jakarta selatan": "-6.2615&106.8106",
-		"jakarta utara":   "-6.1481&106.8998",
-		"bogor":           "-6.2600&106.4800",
-		"depok":           "-6.2350&106.4900",
-		"tangerang":       "-6.1000&106.3000",
-		"bekasi":          "-6.3350&107.1329",
-	}
-
 	// Begin Validation
-	startDate, err := time.Parse(DateHyphenYMD, c.FormValue("start_date"))
+	request, err := parsePredictionForm(c)
 	if err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Parsing Date Error",
-			StatusCode: http.StatusBadRequest,
-			Timestamp:  time.Now().Unix(),
-		})
+		return p.renderPredictionError(c, err)
 	}
-	endDate, err := time.Parse(DateHyphenYMD, c.FormValue("end_date"))
+
+	params, err := request.Validate()
 	if err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Parsing Date Error",
-			StatusCode: http.StatusBadRequest,
-			Timestamp:  time.Now().Unix(),
-		})
+		return p.renderPredictionError(c, err)
 	}
+	// End Validation
 
-	city := c.FormValue("city")
-	if startDate.After(endDate) {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Start Date can't be later than End Date",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	result, err := p.prediction.PredictFlood(c.Request().Context(), params, nil)
+	if err != nil {
+		return p.renderPredictionError(c, err)
 	}
 
-	if int(endDate.Sub(startDate).Hours()/24) < 180 {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Day Count can't be lower than 180 days to ensure proper calculation",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	jsData, err := json.Marshal(result.ChartData())
+	if err != nil {
+		return p.renderPredictionError(c, fmt.Errorf("Marshaling data into json fails, %s", err.Error()))
 	}
 
-	if startDate.Before(startDateLimit) || endDate.After(endDateLimit) {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Date can only be within 2008/01/01 until 2024/09/30",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
-	}
+	return c.Render(http.StatusOK, MainPage, IndexData{
+		Data:       result.ViewData(),
+		JSData:     string(jsData),
+		Message:    fmt.Sprintf("Preparation Done. Time Taken: %dms", result.Duration),
+		StatusCode: http.StatusOK,
+	})
+}
 
-	kValue, err := strconv.Atoi(c.FormValue("k_value"))
-	if err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "K Value is not a valid number",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
-	}
+func parsePredictionForm(c echo.Context) (request PredictionRequest, err error) {
+	request.City = c.FormValue("city")
+	request.StartDate = c.FormValue("start_date")
+	request.EndDate = c.FormValue("end_date")
 
-	if kValue <= 0 || kValue > 500 {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Chosen K Value is not Valid (Must be 1 - 500)",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	request.KValue, err = strconv.Atoi(c.FormValue("k_value"))
+	if err != nil {
+		return request, newPredictionError(http.StatusUnprocessableEntity, "K Value is not a valid number")
 	}
 
 	if FlagV2 {
-		lagOrder, err = strconv.Atoi(c.FormValue("lag_order"))
+		request.LagOrder, err = strconv.Atoi(c.FormValue("lag_order"))
 		if err != nil {
-			return c.Render(http.StatusOK, MainPage, IndexData{
-				Err:        "Lag Order is not a valid number",
-				StatusCode: http.StatusUnprocessableEntity,
-			})
-		}
-
-		if lagOrder <= 0 || lagOrder > 10 {
-			return c.Render(http.StatusOK, MainPage, IndexData{
-				Err:        "Chosen Lag Order is not Valid (Must be 1 - 10)",
-				StatusCode: http.StatusUnprocessableEntity,
-			})
+			return request, newPredictionError(http.StatusUnprocessableEntity, "Lag Order is not a valid number")
 		}
-	} else {
-		lagOrder = 5
 	}
 
-	smoteK, err := strconv.Atoi(c.FormValue("smote_k"))
-	if err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "SMOET K Value is not a valid number",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	request.LagSelection = c.FormValue("lag_selection")
+	request.StationarityPolicy = c.FormValue("stationarity_policy")
+	request.AdfRegression = c.FormValue("adf_regression")
+	request.Transformation = c.FormValue("transformation")
+	request.Model = c.FormValue("model")
+	request.ArimaMethod = c.FormValue("arima_method")
+	request.KnnScaler = c.FormValue("knn_scaler")
+	request.KnnMetric = c.FormValue("knn_metric")
+	request.KnnWeighting = c.FormValue("knn_weighting")
+	if form, err := c.FormParams(); err == nil {
+		request.Exogenous = form["exogenous"]
 	}
 
-	if smoteK <= 0 || smoteK > 10 {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Chosen SMOTE K Value is not Valid (Must be 1 - 10)",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
-	}
-
-	startDateRequest := strings.ReplaceAll(c.FormValue("start_date"), "-", "")
-	endDateRequest := strings.ReplaceAll(c.FormValue("end_date"), "-", "")
-
-	latlong, exists := cities[city]
-	if !exists {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "City is not available",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	if horizon := c.FormValue("forecast_horizon"); horizon != "" {
+		request.ForecastHorizon, err = strconv.Atoi(horizon)
+		if err != nil {
+			return request, newPredictionError(http.StatusUnprocessableEntity, "Forecast Horizon is not a valid number")
+		}
 	}
-	// End Validation
 
-	latitude := strings.Split(latlong, "&")[0]
-	longitude := strings.Split(latlong, "&")[1]
-
-	weathers := Weathers{}
-	nasa := NasaData{}
-	bnpb := BnpbData{}
-	news := NewsData{}
-	url := fmt.Sprintf("%s?start=%s&end=%s&latitude=%s&longitude=%s&%s", constant.NasaPowerAPIBaseURL, startDateRequest, endDateRequest, latitude, longitude, constant.NasaPowerAPIParams)
-	weathers.PrepareNasa(url)
-	if weathers.Err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Fetching Data from NASA Power API Fails",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	if p := c.FormValue("minkowski_p"); p != "" {
+		request.MinkowskiP, err = strconv.ParseFloat(p, 64)
+		if err != nil {
+			return request, newPredictionError(http.StatusUnprocessableEntity, "Minkowski P is not a valid number")
+		}
 	}
 
-	cmd := exec.Command("python", "/home/vasti/Hobby/skripsi/granger_causality_test.py")
-	cmd.Run()
-
-	weathers.InjectNasa(&nasa)
-	if weathers.Err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Preparing Data from NASA Power API Fails",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	if threshold := c.FormValue("knn_threshold"); threshold != "" {
+		request.KnnThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return request, newPredictionError(http.StatusUnprocessableEntity, "KNN Threshold is not a valid number")
+		}
 	}
-	nasa.Stats()
 
-	weathers.InjectBnpb(&bnpb, startDate, endDate, city)
-	if weathers.Err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Preparing Data from BNPB Fails",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
+	request.SmoteK, err = strconv.Atoi(c.FormValue("smote_k"))
+	if err != nil {
+		return request, newPredictionError(http.StatusUnprocessableEntity, "SMOET K Value is not a valid number")
 	}
 
-	weathers.InjectNews(&news, startDate, endDate, city)
-	if weathers.Err != nil {
-		return c.Render(http.StatusOK, MainPage, IndexData{
-			Err:        "Preparing Data from News Fails",
-			StatusCode: http.StatusUnprocessableEntity,
-		})
-	}
+	return
+}
 
-	differencedWeathers := weathers.Differencing()
-
-	prediction := differencedWeathers.VectorAutoregression(lagOrder)
-	prediction.FillString()
-	vectorAutoregressionEvaluation := differencedWeathers.VectorAutoregressionEval(6, 5, lagOrder)
-	neighbors, knnResult := differencedWeathers.KNearestNeighbor(kValue, prediction, false)
-	knnEval := differencedWeathers.KNearestNeighborEval(6, 5, kValue, lagOrder, false)
-
-	oversampled := differencedWeathers.SmoteOversampling(smoteK, nasa)
-	smoteNeighbors, smoteKnnResult := oversampled.KNearestNeighbor(kValue, prediction, true)
-	smoteKnnEval := oversampled.KNearestNeighborEval(6, 5, kValue, lagOrder, true)
-
-	statistics := Statistics{
-		Ref: StatisticsReference{
-			Nasa:                &nasa,
-			Bnpb:                &bnpb,
-			News:                &news,
-			Weathers:            &weathers,
-			DifferencedWeathers: &differencedWeathers,
-			Smote:               &oversampled,
-		},
-	}
-	predictionMap := []KeyValue{
-		{Key: "WS10M", Value: prediction.WindSpeedStr},
-		{Key: "RH2M", Value: prediction.RelHumidityStr},
-		{Key: "PRECTOTCORR", Value: prediction.PrecipitationStr},
-		{Key: "T2M", Value: prediction.TempAverageStr},
-		{Key: "T2M_MAX", Value: prediction.TempMaxStr},
-		{Key: "T2M_MIN", Value: prediction.TempMinStr},
-	}
-
-	statistics.FillStatistics(startDate, endDate, city)
-	weathers.FillString()
-	differencedWeathers.FillString()
-	neighbors.FillString()
-	oversampled.FillString()
-	smoteNeighbors.FillString()
-	p.logger.LogAndContinue("Done Processing Request")
-	structToJsonFile(weathers, "weathers.json")
-	viewData := map[string]interface{}{
-		"NasaHeaders":                       []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN"},
-		"NasaStats":                         []Nasa{nasa.Max, nasa.Min, nasa.Mean, nasa.Variance, nasa.StdDev},
-		"NasaValues":                        nasa.Items,
-		"BnpbHeaders":                       []string{"Kode Identitas Bencana", "ID Kabupaten", "Tanggal Kejadian", "Kejadian", "Lokasi", "Kabupaten", "Provinsi", "Penyebab"},
-		"BnpbValues":                        bnpb.Items,
-		"NewsHeaders":                       []string{"Kota", "Tanggal", "Link Berita"},
-		"NewsValues":                        news.Items,
-		"WeatherAndFloodHeaders":            []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
-		"WeatherAndFloodValues":             weathers.Items,
-		"DifferencedWeatherAndFloodHeaders": []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
-		"DifferencedWeatherAndFloodValues":  differencedWeathers.Items,
-		"DifferencedWeatherAndFloodStats":   differencedWeathers.Diff,
-		"VectorAutoregressionHeaders":       []string{"TRAIN-TEST (%)", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN"},
-		"VectorAutoregressionValues":        vectorAutoregressionEvaluation.Items,
-		"VectorAutoregressionResult":        predictionMap,
-		"KNNHeaders":                        []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "DISTANCE", "FLOOD"},
-		"KNNValues":                         neighbors.Items,
-		"KNNResult":                         knnResult,
-		"KNNEvalHeaders":                    []string{"TRAIN-TEST (%)", "TP", "FP", "TN", "FN", "ACCURACY", "PRECISION", "RECALL", "F1-SCORE"},
-		"KNNEvalValues":                     knnEval,
-		"SMOTEHeaders":                      []string{"DATE", "WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "FLOOD"},
-		"SMOTEValues":                       oversampled.SynthItems,
-		"SMOTEKNNHeaders":                   []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M MAX", "T2M MIN", "DISTANCE", "FLOOD"},
-		"SMOTEKNNValues":                    smoteNeighbors.Items,
-		"SMOTEKNNResult":                    smoteKnnResult,
-		"SMOTEKNNEvalHeaders":               []string{"TRAIN-TEST (%)", "TP", "FP", "TN", "FN", "ACCURACY", "PRECISION", "RECALL", "F1-SCORE"},
-		"SMOTEKNNEvalValues":                smoteKnnEval,
-		"Statistics":                        statistics,
-		"Latitude":                          latitude,
-		"Longitude":                         longitude,
-		"Timestamp":                         time.Now().Unix(),
+// renderPredictionError keeps the page at 200 so htmx still swaps in the error banner.
+func (p *WebProcessorImpl) renderPredictionError(c echo.Context, err error) error {
+	statusCode := http.StatusInternalServerError
+	if predictionErr, ok := err.(*PredictionError); ok {
+		statusCode = predictionErr.StatusCode
 	}
 
 	return c.Render(http.StatusOK, MainPage, IndexData{
-		Data:       viewData,
-		Message:    fmt.Sprintf("Preparation Done. Time Taken: %dms", time.Since(start).Milliseconds()),
-		StatusCode: http.StatusOK,
+		Err:        err.Error(),
+		StatusCode: statusCode,
+		Timestamp:  time.Now().Unix(),
 	})
 }
 
-func (w *Weathers) PrepareNasa(url string) {
-	resp, err := http.Get(url)
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error fetching from url: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	wd, err := os.Getwd()
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error getting working directory: %v", err)
-		return
-	}
-
-	tempFile, err := os.Create(filepath.Join(wd, "tmp/nasa_data.txt"))
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error creating temp file: %v", err)
-		return
-	}
-
-	_, err = io.Copy(tempFile, resp.Body)
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error saving repsonse data to temp file: %v", err)
-		return
-	}
-	tempFile.Close()
-
-	tempFile, err = os.Open(filepath.Join(wd, "tmp/nasa_data.txt"))
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error opening temp file: %v", err)
-		return
-	}
-	defer tempFile.Close()
-
-	csvFile, err := os.Create(filepath.Join(wd, "tmp/nasa_data.csv"))
+func (w *Weathers) PrepareNasa(ctx context.Context, source WeatherSource, query WeatherQuery, nasa *NasaData) {
+	items, err := source.Fetch(ctx, query)
 	if err != nil {
 		w.Err = err
-		fmt.Printf("[NASA-FETCH] error creating csv: %v", err)
+		fmt.Printf("[NASA-FETCH] error fetching weather data: %v", err)
 		return
 	}
-	defer csvFile.Close()
-
-	scanner := bufio.NewScanner(tempFile)
-	lineCount := 0
+	nasa.Items = items
+}
 
+// parseNasaResponse reads a NASA POWER daily CSV response, header block included, into typed rows.
+func parseNasaResponse(r io.Reader) (items []Nasa, err error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		lineCount++
-
-		if lineCount > 14 {
-			csvLine := scanner.Text()
-			csvLine = strings.ReplaceAll(csvLine, "\t", ",")
-			_, err := csvFile.WriteString(csvLine + "\n")
-			if err != nil {
-				w.Err = err
-				fmt.Printf("[NASA-FETCH] error writing to csv: %v", err)
-				return
-			}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "-END HEADER-" {
+			lines = lines[:0]
+			continue
 		}
+		lines = append(lines, strings.ReplaceAll(line, "\t", ","))
 	}
-
-	if err := scanner.Err(); err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-FETCH] error scanning temp file io content: %v", err)
-		return
-	}
-}
-
-func (w *Weathers) InjectNasa(nasa *NasaData) {
-	wd, err := os.Getwd()
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-INJECT] error getting working directory: %v", err)
-		return
-	}
-
-	csvFile, err := os.Open(filepath.Join(wd, "tmp/nasa_data.csv"))
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-INJECT] error opening csv: %v", err)
-		return
+	if err = scanner.Err(); err != nil {
+		return nil, err
 	}
-	defer csvFile.Close()
 
-	reader := csv.NewReader(csvFile)
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
 	records, err := reader.ReadAll()
 	if err != nil {
-		w.Err = err
-		fmt.Printf("[NASA-INJECT] error reading csv file: %v", err)
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("response has no data rows")
 	}
 
 	headers := records[0][2:]
@@ -368,15 +166,18 @@ func (w *Weathers) InjectNasa(nasa *NasaData) {
 	for i, header := range headers {
 		headersIndex[header] = i
 	}
+	for _, header := range []string{"WS10M", "RH2M", "PRECTOTCORR", "T2M", "T2M_MAX", "T2M_MIN"} {
+		if _, exists := headersIndex[header]; !exists {
+			return nil, fmt.Errorf("response is missing the %s column", header)
+		}
+	}
 
-	records = records[1:]
-	for _, record := range records {
+	for _, record := range records[1:] {
 		year, _ := strconv.Atoi(record[0])
 		doy, _ := strconv.Atoi(record[1])
 
 		startOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
 		date := startOfYear.AddDate(0, 0, doy-1)
-		dateStr := date.Format(DateHyphenYMD)
 
 		data := record[2:]
 		ws10m, _ := strconv.ParseFloat(data[headersIndex["WS10M"]], 64)
@@ -386,8 +187,9 @@ func (w *Weathers) InjectNasa(nasa *NasaData) {
 		t2mMax, _ := strconv.ParseFloat(data[headersIndex["T2M_MAX"]], 64)
 		t2mMin, _ := strconv.ParseFloat(data[headersIndex["T2M_MIN"]], 64)
 
-		nasa.Items = append(nasa.Items, Nasa{
-			DateStr:          dateStr,
+		items = append(items, Nasa{
+			Date:             date,
+			DateStr:          date.Format(DateHyphenYMD),
 			WindSpeed:        ws10m,
 			RelHumidity:      rh2m,
 			Precipitation:    prectotcorr,
@@ -401,281 +203,151 @@ func (w *Weathers) InjectNasa(nasa *NasaData) {
 			TempMaxStr:       data[headersIndex["T2M_MAX"]],
 			TempMinStr:       data[headersIndex["T2M_MIN"]],
 		})
-		w.Items = append(w.Items, Weather{
-			Date:          date,
-			WindSpeed:     ws10m,
-			RelHumidity:   rh2m,
-			Precipitation: prectotcorr,
-			TempAverage:   t2m,
-			TempMax:       t2mMax,
-			TempMin:       t2mMin,
-		})
 	}
+	return
 }
 
-func (w *Weathers) InjectBnpb(bnpb *BnpbData, startDate, endDate time.Time, city string) {
-	wd, err := os.Getwd()
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[BNPB-INJECT] error getting working directory: %v", err)
+func (w *Weathers) InjectNasa(nasa *NasaData) {
+	if len(nasa.Items) == 0 {
+		w.Err = fmt.Errorf("no NASA data to inject")
+		fmt.Printf("[NASA-INJECT] error injecting data: %v", w.Err)
 		return
 	}
 
-	csvFile, err := os.Open(filepath.Join(wd, "tmp/bnpb_data.csv"))
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[BNPB-INJECT] error opening csv: %v", err)
-		return
+	for _, n := range nasa.Items {
+		w.Items = append(w.Items, Weather{
+			Date:          n.Date,
+			WindSpeed:     n.WindSpeed,
+			RelHumidity:   n.RelHumidity,
+			Precipitation: n.Precipitation,
+			TempAverage:   n.TempAverage,
+			TempMax:       n.TempMax,
+			TempMin:       n.TempMin,
+		})
 	}
-	defer csvFile.Close()
+}
 
-	reader := csv.NewReader(csvFile)
-	records, err := reader.ReadAll()
+func (w *Weathers) InjectBnpb(ctx context.Context, source FloodEventSource, bnpb *BnpbData, startDate, endDate time.Time, city string) {
+	events, err := source.BnpbEvents(ctx, city, startDate, endDate)
 	if err != nil {
 		w.Err = err
-		fmt.Printf("[BNPB-INJECT] error reading csv: %v", err)
+		fmt.Printf("[BNPB-INJECT] error reading flood events: %v", err)
 		return
 	}
 
-	indexCode := 1
-	indexCityID := 2
-	indexDate := 3
-	indexOccurence := 4
-	indexLocation := 5
-	indexCity := 6
-	indexProvince := 7
-	indexCause := 9
-	floodDates := make(map[string][]string)
-
-	for _, record := range records {
-		if !strings.Contains(strings.ToLower(record[indexCity]), city) {
-			continue
-		}
-
-		dateStr := record[indexDate]
-		date, _ := time.Parse("02/01/2006", dateStr)
-		if date.After(endDate) || date.Before(startDate) {
-			continue
-		}
-
-		if _, exists := floodDates[dateStr]; !exists {
-			floodDates[dateStr] = record
+	floodDates := make(map[string]Bnpb)
+	for _, event := range events {
+		if _, exists := floodDates[event.Date]; !exists {
+			floodDates[event.Date] = event
 		}
 	}
 
 	for i, d := range w.Items {
-		dateStr := d.Date.Format("02/01/2006")
-		if record, exists := floodDates[dateStr]; exists {
-			bnpb.Items = append(bnpb.Items, Bnpb{
-				Code:      record[indexCode],
-				CityID:    record[indexCityID],
-				Date:      record[indexDate],
-				Occurence: record[indexOccurence],
-				Location:  record[indexLocation],
-				City:      record[indexCity],
-				Province:  record[indexProvince],
-				Cause:     record[indexCause],
-			})
+		if event, exists := floodDates[d.Date.Format(DateSlashDMY)]; exists {
+			bnpb.Items = append(bnpb.Items, event)
 			w.Items[i].Flood = true
 		}
 	}
 }
 
-func (w *Weathers) InjectNews(news *NewsData, startDate, endDate time.Time, city string) {
-	wd, err := os.Getwd()
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NEWS-INJECT] error getting working directory: %v", err)
-		return
-	}
-
-	csvFile, err := os.Open(filepath.Join(wd, "tmp/data_berita_banjir.csv"))
+func (w *Weathers) InjectNews(ctx context.Context, source FloodEventSource, news *NewsData, startDate, endDate time.Time, city string) {
+	events, err := source.NewsEvents(ctx, city, startDate, endDate)
 	if err != nil {
 		w.Err = err
-		fmt.Printf("[NEWS-INJECT] error opening csv: %v", err)
+		fmt.Printf("[NEWS-INJECT] error reading flood events: %v", err)
 		return
 	}
-	defer csvFile.Close()
 
-	reader := csv.NewReader(csvFile)
-	records, err := reader.ReadAll()
-	if err != nil {
-		w.Err = err
-		fmt.Printf("[NEWS-INJECT] error reading csv: %v", err)
-		return
-	}
-
-	indexCity := 0
-	indexDate := 1
-	indexLink := 2
-	floodDates := make(map[string][]string)
-
-	for _, record := range records {
-		if !strings.Contains(strings.ToLower(record[indexCity]), city) {
-			continue
-		}
-
-		dateStr := record[indexDate]
-		date, _ := time.Parse("2006/01/02", dateStr)
-		if date.After(endDate) || date.Before(startDate) {
-			continue
-		}
-
-		if _, exists := floodDates[dateStr]; !exists {
-			floodDates[dateStr] = record
+	floodDates := make(map[string]News)
+	for _, event := range events {
+		if _, exists := floodDates[event.Date]; !exists {
+			floodDates[event.Date] = event
 		}
 	}
 
 	for i, d := range w.Items {
-		dateStr := d.Date.Format("2006/01/02")
-		if record, exists := floodDates[dateStr]; exists {
-			news.Items = append(news.Items, News{
-				City: record[indexCity],
-				Date: record[indexDate],
-				Link: template.HTML(fmt.Sprintf("<a class=\"text-blue-800\" href=\"%s\">Link</a>", record[indexLink])),
-			})
+		if event, exists := floodDates[d.Date.Format(DateSlashYMD)]; exists {
+			news.Items = append(news.Items, event)
 			w.Items[i].Flood = true
 		}
 	}
 }
 
-func (w *Weathers) Differencing() (differencedWeathers Weathers) {
-	var (
-		steps                                                                                                                                        int
-		windSpeed, relHumidity, precipitation, tempAverage, tempMax, tempMin                                                                         []float64
-		critValWindSpeed, critValRelHumidity, critValPrecipitation, critValTempAverage, critValTempMax, critValTempMin                               float64
-		gammaWindSpeed, gammaRelHumidity, gammaPrecipitation, gammaTempAverage, gammaTempMax, gammaTempMin                                           float64
-		windSpeedStationarity, relHumidityStationarity, precipitationStationarity, tempAverageStationarity, tempMaxStationarity, tempMinStationarity bool
-	)
+// Differencing differences each variable until the stationarity policy finds it stationary, so every variable
+// keeps its own integration order. The first rows are dropped so all variables start on the same date, and
+// the report keeps the test results of the final series. With the STL transformation the residuals of the
+// seasonal decomposition are used instead.
+func (w *Weathers) Differencing(options StationarityOptions) (differencedWeathers Weathers) {
+	if options.Transformation == TransformationStl {
+		return w.deseasonalize(options)
+	}
 
-	for i := 0; i < len(w.Items); i++ {
-		windSpeed = append(windSpeed, w.Items[i].WindSpeed)
-		relHumidity = append(relHumidity, w.Items[i].RelHumidity)
-		precipitation = append(precipitation, w.Items[i].Precipitation)
-		tempAverage = append(tempAverage, w.Items[i].TempAverage)
-		tempMax = append(tempMax, w.Items[i].TempMax)
-		tempMin = append(tempMin, w.Items[i].TempMin)
-	}
-
-	stationary := false
-	for !stationary {
-		windSpeedStationarity, critValWindSpeed, gammaWindSpeed, _ = adfTest(windSpeed)
-		relHumidityStationarity, critValRelHumidity, gammaRelHumidity, _ = adfTest(relHumidity)
-		precipitationStationarity, critValPrecipitation, gammaPrecipitation, _ = adfTest(precipitation)
-		tempAverageStationarity, critValTempAverage, gammaTempAverage, _ = adfTest(tempAverage)
-		tempMaxStationarity, critValTempMax, gammaTempMax, _ = adfTest(tempMax)
-		tempMinStationarity, critValTempMin, gammaTempMin, _ = adfTest(tempMin)
-
-		if windSpeedStationarity && relHumidityStationarity && precipitationStationarity && tempAverageStationarity && tempMaxStationarity && tempMinStationarity {
-			stationary = true
-			break
+	series := make([][]float64, len(varVariables))
+	for _, d := range w.Items {
+		for v, value := range weatherValues(d) {
+			series[v] = append(series[v], value)
 		}
-
-		windSpeed = difference(windSpeed)
-		relHumidity = difference(relHumidity)
-		precipitation = difference(precipitation)
-		tempAverage = difference(tempAverage)
-		tempMax = difference(tempMax)
-		tempMin = difference(tempMin)
-		steps++
-	}
-
-	for i := 0; i < len(windSpeed); i++ {
-		differencedWeathers.Items = append(differencedWeathers.Items, Weather{
-			Date:          w.Items[steps+i].Date,
-			WindSpeed:     windSpeed[i],
-			RelHumidity:   relHumidity[i],
-			Precipitation: precipitation[i],
-			TempAverage:   tempAverage[i],
-			TempMax:       tempMax[i],
-			TempMin:       tempMin[i],
-			Flood:         w.Items[steps+i].Flood,
-		})
-	}
-	differencedWeathers.Diff.Step = steps
-	differencedWeathers.Diff.CriticalValues = Weather{
-		WindSpeed:     critValWindSpeed,
-		RelHumidity:   critValRelHumidity,
-		Precipitation: critValPrecipitation,
-		TempAverage:   critValTempAverage,
-		TempMax:       critValTempMax,
-		TempMin:       critValTempMin,
-	}
-	differencedWeathers.Diff.Gamma = Weather{
-		WindSpeed:     gammaWindSpeed,
-		RelHumidity:   gammaRelHumidity,
-		Precipitation: gammaPrecipitation,
-		TempAverage:   gammaTempAverage,
-		TempMax:       gammaTempMax,
-		TempMin:       gammaTempMin,
-	}
-
-	differencedWeathers.Diff.CriticalValuesGammaMap = []KeyValue{
-		{Key: "WS10M", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.WindSpeed, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.WindSpeed, 'f', 5, 64))},
-		{Key: "RH2M", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.RelHumidity, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.RelHumidity, 'f', 5, 64))},
-		{Key: "PRECTOTCORR", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.Precipitation, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.Precipitation, 'f', 5, 64))},
-		{Key: "T2M", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.TempAverage, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.TempAverage, 'f', 5, 64))},
-		{Key: "T2M_MAX", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.TempMax, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.TempMax, 'f', 5, 64))},
-		{Key: "T2M_MIN", Value: fmt.Sprintf("Critical Value: %s > %s", strconv.FormatFloat(differencedWeathers.Diff.CriticalValues.TempMin, 'f', 5, 64), strconv.FormatFloat(differencedWeathers.Diff.Gamma.TempMin, 'f', 5, 64))},
 	}
 
-	return
-}
-
-func (w *Weathers) VectorAutoregression(lagOrder int) (prediction Weather) {
-	numOfVariables := 6
-	matrixForm := make([][]float64, len(w.Items))
-	for i, d := range w.Items {
-		matrixForm[i] = []float64{d.WindSpeed, d.RelHumidity, d.Precipitation, d.TempAverage, d.TempMax, d.TempMin}
-	}
-	rowCount := len(matrixForm) - lagOrder
+	orders := make([]int, len(series))
+	results := make([]VariableStationarity, len(series))
+	for v := range series {
+		for {
+			result, err := TestStationarity(series[v], options)
+			if err != nil {
+				fmt.Printf("[DIFFERENCING] error testing %s for stationarity: %v", varVariables[v], err)
+				differencedWeathers.Err = err
+				return
+			}
+			result.Variable = varVariables[v]
+			result.Order = orders[v]
+			results[v] = result
+			if result.Stationary {
+				break
+			}
 
-	responseSlice := make([][]float64, rowCount)
-	for i := 0; i < rowCount; i++ {
-		responseSlice[i] = matrixForm[lagOrder+i]
+			if orders[v] == MaxDifferencingSteps {
+				differencedWeathers.Err = fmt.Errorf("%s is not stationary after %d differences", varVariables[v], orders[v])
+				fmt.Printf("[DIFFERENCING] %v", differencedWeathers.Err)
+				return
+			}
+			series[v] = difference(series[v])
+			orders[v]++
+		}
 	}
 
-	regressorSlice := make([][]float64, rowCount)
-	for i := 0; i < rowCount; i++ {
-		row := []float64{1.0}
-		for lag := 1; lag <= lagOrder; lag++ {
-			row = append(row, matrixForm[lagOrder+i-lag]...)
+	// A variable differenced d times starts at original row d, the aligned rows start at the highest order.
+	steps := slices.Max(orders)
+	for i := steps; i < len(w.Items); i++ {
+		values := make([]float64, len(series))
+		for v := range series {
+			values[v] = series[v][i-orders[v]]
 		}
-		regressorSlice[i] = row
+		weather := weatherFromSlice(values)
+		weather.Date = w.Items[i].Date
+		weather.Flood = w.Items[i].Flood
+		differencedWeathers.Items = append(differencedWeathers.Items, weather)
 	}
+	differencedWeathers.Diff.Transformation = TransformationDifference
+	differencedWeathers.Diff.Step = steps
+	differencedWeathers.Diff.Orders = orders
+	differencedWeathers.Diff.Policy = options.Policy
+	differencedWeathers.Diff.Stationarity = results
+	differencedWeathers.Diff.Origin = w.Items
+	differencedWeathers.Diff.fillAnchors()
 
-	responseMatrix := mat.NewDense(len(responseSlice), len(responseSlice[0]), flatten(responseSlice))
-	regressorMatrix := mat.NewDense(len(regressorSlice), len(regressorSlice[0]), flatten(regressorSlice))
-
-	var xTx mat.Dense
-	xTx.Mul(regressorMatrix.T(), regressorMatrix)
+	return
+}
 
-	var xTxInv mat.Dense
-	if err := xTxInv.Inverse(&xTx); err != nil {
+func (w *Weathers) VectorAutoregression(lagOrder int) (prediction Weather) {
+	model, err := w.FitVectorAutoregression(lagOrder)
+	if err != nil {
+		w.Err = err
+		fmt.Printf("[VAR] error fitting model: %v", err)
 		return
 	}
 
-	var xTy mat.Dense
-	xTy.Mul(regressorMatrix.T(), responseMatrix)
-
-	var B mat.Dense
-	B.Mul(&xTxInv, &xTy)
-
-	result := make([][]float64, B.RawMatrix().Rows)
-	for i := 0; i < len(result); i++ {
-		result[i] = B.RawRowView(i)
-	}
-	result = transpose(result)
-
-	predictionSlice := make([]float64, 6)
-	for i, d := range result {
-		predictionSlice[i] = d[0]
-		for j := 1; j < len(d); j++ {
-			previousIndex := len(matrixForm) - ((j-1)/6 + 1)
-			predictionSlice[i] += d[j] * matrixForm[previousIndex][(j-1)-numOfVariables*((j-1)/numOfVariables)]
-		}
-	}
-
+	predictionSlice := model.Forecast(w.varMatrix())
 	prediction.WindSpeed = predictionSlice[0]
 	prediction.RelHumidity = predictionSlice[1]
 	prediction.Precipitation = predictionSlice[2]
@@ -686,13 +358,21 @@ func (w *Weathers) VectorAutoregression(lagOrder int) (prediction Weather) {
 	return
 }
 
-func (w *Weathers) VectorAutoregressionEval(step, magnitude, lagOrder int) (evaluatedNrmse Weathers) {
+// VectorAutoregressionEval scores one step ahead predictions over growing test splits, of the VARX when exogenous
+// has regressors. For a differenced series the predictions are also integrated back and scored against the original
+// days in originalNrmse.
+func (w *Weathers) VectorAutoregressionEval(ctx context.Context, step, magnitude, lagOrder int, exogenous ExogenousOptions) (evaluatedNrmse, originalNrmse Weathers) {
 	if magnitude*step > 100 {
 		return
 	}
 
 	max, min := w.GetMaxMin()
-	nrmseEval := make([]Weather, step)
+	var nrmseEval, originalEval []Weather
+	original := Weathers{Items: w.Diff.Origin}
+	var originalMax, originalMin Weather
+	if w.HasOrigin() {
+		originalMax, originalMin = original.GetMaxMin()
+	}
 
 	for i := 1; i <= step; i++ {
 		testPerc := fmt.Sprintf("%d", i*magnitude)
@@ -702,106 +382,94 @@ func (w *Weathers) VectorAutoregressionEval(step, magnitude, lagOrder int) (eval
 		testSize := len(w.Items) * test / 100
 		trainSize := len(w.Items) - testSize
 
-		rmse := Weather{}
-		nrmse := Weather{}
+		squaredError := Weather{}
+		originalSquaredError := Weather{}
 		predictionCount := 0
 
+		// STL residuals are recomputed from the training days, otherwise the seasonal component of a test day
+		// would be smoothed with the day itself. A split with less than two periods of training days is skipped.
+		split := w
+		if w.Diff.Transformation == TransformationStl {
+			stlSplit, err := w.stlSplit(trainSize)
+			if err != nil {
+				continue
+			}
+			split = &stlSplit
+		}
+
+		// Every fold refits on the days before j, the differencing statistics letting the VARX read the rainfall of
+		// the original days. A fold whose design matrix is singular is left out rather than scored as a zero
+		// prediction.
+		rolling := split.newRollingVar(lagOrder, exogenous)
 		for j := trainSize; j < len(w.Items)-1; j++ {
-			trainSlice := w.Items[:j]
-			trainDataset := Weathers{
-				Items: trainSlice,
+			if ctx.Err() != nil {
+				return
 			}
 
-			predicted := trainDataset.VectorAutoregression(lagOrder)
-			actual := w.Items[j]
+			predicted, err := rolling.Predict(j)
+			if err != nil {
+				continue
+			}
+			actual := split.Items[j]
+			addSquaredError(&squaredError, predicted, actual)
 
-			rmse.WindSpeed += math.Pow(predicted.WindSpeed-actual.WindSpeed, 2)
-			rmse.RelHumidity += math.Pow(predicted.RelHumidity-actual.RelHumidity, 2)
-			rmse.Precipitation += math.Pow(predicted.Precipitation-actual.Precipitation, 2)
-			rmse.TempAverage += math.Pow(predicted.TempAverage-actual.TempAverage, 2)
-			rmse.TempMax += math.Pow(predicted.TempMax-actual.TempMax, 2)
-			rmse.TempMin += math.Pow(predicted.TempMin-actual.TempMin, 2)
+			if w.HasOrigin() {
+				addSquaredError(&originalSquaredError, split.Integrate([]Weather{predicted}, j-1)[0], w.Diff.Origin[j+w.Diff.Step])
+			}
 
 			predictionCount++
 		}
 
-		predictionSize := float64(predictionCount)
-		rmse.WindSpeed = math.Sqrt(rmse.WindSpeed / predictionSize)
-		rmse.RelHumidity = math.Sqrt(rmse.RelHumidity / predictionSize)
-		rmse.Precipitation = math.Sqrt(rmse.Precipitation / predictionSize)
-		rmse.TempAverage = math.Sqrt(rmse.TempAverage / predictionSize)
-		rmse.TempMax = math.Sqrt(rmse.TempMax / predictionSize)
-		rmse.TempMin = math.Sqrt(rmse.TempMin / predictionSize)
-
-		nrmse.WindSpeed = rmse.WindSpeed / (max.WindSpeed - min.WindSpeed)
-		nrmse.RelHumidity = rmse.RelHumidity / (max.RelHumidity - min.RelHumidity)
-		nrmse.Precipitation = rmse.Precipitation / (max.Precipitation - min.Precipitation)
-		nrmse.TempAverage = rmse.TempAverage / (max.TempAverage - min.TempAverage)
-		nrmse.TempMax = rmse.TempMax / (max.TempMax - min.TempMax)
-		nrmse.TempMin = rmse.TempMin / (max.TempMin - min.TempMin)
-
-		nrmseEval[i-1] = nrmse
-		nrmseEval[i-1].FillString()
-		nrmseEval[i-1].DateStr = fmt.Sprintf("%s - %s", trainPerc, testPerc)
+		nrmse := normalizedRmse(squaredError, predictionCount, max, min)
+		nrmse.FillString()
+		nrmse.DateStr = fmt.Sprintf("%s - %s", trainPerc, testPerc)
+		nrmseEval = append(nrmseEval, nrmse)
+
+		if w.HasOrigin() {
+			integrated := normalizedRmse(originalSquaredError, predictionCount, originalMax, originalMin)
+			integrated.FillString()
+			integrated.DateStr = nrmse.DateStr
+			originalEval = append(originalEval, integrated)
+		}
 	}
 	evaluatedNrmse.Items = nrmseEval
+	originalNrmse.Items = originalEval
 	return
 }
 
-func (w *Weathers) KNearestNeighbor(kValue int, new Weather, withSynth bool) (neighbors Weathers, result string) {
-	tempW := Weathers{}
-	if withSynth {
-		tempW.Items = make([]Weather, len(w.SynthItems))
-
-		for i, d := range w.SynthItems {
-			distance := math.Pow(new.WindSpeed-d.WindSpeed, 2)
-			distance += math.Pow(new.RelHumidity-d.RelHumidity, 2)
-			distance += math.Pow(new.Precipitation-d.Precipitation, 2)
-			distance += math.Pow(new.TempAverage-d.TempAverage, 2)
-			distance += math.Pow(new.TempMax-d.TempMax, 2)
-			distance += math.Pow(new.TempMin-d.TempMin, 2)
-
-			d.Distance = math.Sqrt(distance)
-			tempW.Items[i] = d
-		}
-	} else {
-		tempW.Items = make([]Weather, len(w.Items))
-
-		for i, d := range w.Items {
-			distance := math.Pow(new.WindSpeed-d.WindSpeed, 2)
-			distance += math.Pow(new.RelHumidity-d.RelHumidity, 2)
-			distance += math.Pow(new.Precipitation-d.Precipitation, 2)
-			distance += math.Pow(new.TempAverage-d.TempAverage, 2)
-			distance += math.Pow(new.TempMax-d.TempMax, 2)
-			distance += math.Pow(new.TempMin-d.TempMin, 2)
+func addSquaredError(sum *Weather, predicted, actual Weather) {
+	sum.WindSpeed += math.Pow(predicted.WindSpeed-actual.WindSpeed, 2)
+	sum.RelHumidity += math.Pow(predicted.RelHumidity-actual.RelHumidity, 2)
+	sum.Precipitation += math.Pow(predicted.Precipitation-actual.Precipitation, 2)
+	sum.TempAverage += math.Pow(predicted.TempAverage-actual.TempAverage, 2)
+	sum.TempMax += math.Pow(predicted.TempMax-actual.TempMax, 2)
+	sum.TempMin += math.Pow(predicted.TempMin-actual.TempMin, 2)
+}
 
-			d.Distance = math.Sqrt(distance)
-			tempW.Items[i] = d
-		}
-	}
+// normalizedRmse divides the root mean squared error by the observed range of each variable.
+func normalizedRmse(squaredError Weather, count int, max, min Weather) (nrmse Weather) {
+	size := float64(count)
+	nrmse.WindSpeed = math.Sqrt(squaredError.WindSpeed/size) / (max.WindSpeed - min.WindSpeed)
+	nrmse.RelHumidity = math.Sqrt(squaredError.RelHumidity/size) / (max.RelHumidity - min.RelHumidity)
+	nrmse.Precipitation = math.Sqrt(squaredError.Precipitation/size) / (max.Precipitation - min.Precipitation)
+	nrmse.TempAverage = math.Sqrt(squaredError.TempAverage/size) / (max.TempAverage - min.TempAverage)
+	nrmse.TempMax = math.Sqrt(squaredError.TempMax/size) / (max.TempMax - min.TempMax)
+	nrmse.TempMin = math.Sqrt(squaredError.TempMin/size) / (max.TempMin - min.TempMin)
+	return
+}
 
-	tempW.SortByDistance()
-	vote := make([]int, 2)
-	neighbors.Items = make([]Weather, kValue)
-	for i, d := range tempW.Items {
-		if i >= kValue {
-			break
-		}
-		if !d.Flood {
-			vote[0] += 1
-		} else {
-			vote[1] += 1
-		}
-		neighbors.Items[i] = d
-	}
+// KNearestNeighbor votes among the kValue items, or synthetic items with withSynth, nearest to new. The scaler
+// and metric of options are fitted on the items searched, never on new, and the votes are weighted by options.
+func (w *Weathers) KNearestNeighbor(kValue int, new Weather, withSynth bool, options KnnOptions) (neighbors Weathers, result KnnClassification) {
+	return w.knnIndex(withSynth, options).KNearestNeighbor(kValue, new)
+}
 
-	if vote[0] >= vote[1] {
-		result = "No Flood"
-	} else {
-		result = "Flood"
+// knnIndex indexes the items, or synthetic items with withSynth, for repeated neighbor searches.
+func (w *Weathers) knnIndex(withSynth bool, options KnnOptions) *knnIndex {
+	if withSynth {
+		return newKnnIndex(w.SynthItems, options)
 	}
-
-	return
+	return newKnnIndex(w.Items, options)
 }
 
 func (w *Weathers) KNearestNeighborMinority(kValue int, new Weather) (neighbors Weathers, result string) {
@@ -847,7 +515,10 @@ func (w *Weathers) KNearestNeighborMinority(kValue int, new Weather) (neighbors
 	return
 }
 
-func (w *Weathers) KNearestNeighborEval(step, magnitude, kValue, lagOrder int, withSynth bool) (confusionMatrix []ConfusionMatrix) {
+// KNearestNeighborEval classifies the VAR prediction of every test day at the threshold of options, and scores
+// the flood probabilities of the split over all thresholds. Each fold searches only the days before it, so the
+// KNN scaler is fitted on the training part of the fold alone.
+func (w *Weathers) KNearestNeighborEval(ctx context.Context, step, magnitude, kValue, lagOrder int, withSynth bool, options KnnOptions) (confusionMatrix []ConfusionMatrix) {
 	if magnitude*step > 100 {
 		return
 	}
@@ -861,7 +532,6 @@ func (w *Weathers) KNearestNeighborEval(step, magnitude, kValue, lagOrder int, w
 		copy(tempW.Items, w.Items)
 	}
 
-	confusionMatrix = make([]ConfusionMatrix, step)
 	for i := 1; i <= step; i++ {
 		testPerc := fmt.Sprintf("%d", i*magnitude)
 		trainPerc := fmt.Sprintf("%d", 100-i*magnitude)
@@ -870,42 +540,43 @@ func (w *Weathers) KNearestNeighborEval(step, magnitude, kValue, lagOrder int, w
 		testSize := len(tempW.Items) * test / 100
 		trainSize := len(tempW.Items) - testSize
 
-		predictionCount := 0
-
-		for j := trainSize; j < len(tempW.Items)-1; j++ {
-			trainSlice := tempW.Items[:j]
-			trainDataset := Weathers{
-				Items:      trainSlice,
-				SynthItems: trainSlice,
+		// As in VectorAutoregressionEval, STL residuals are recomputed from the training days and a split with less
+		// than two periods of them is skipped. The synthetic days leading SynthItems keep their residuals.
+		split := tempW
+		if w.Diff.Transformation == TransformationStl {
+			synthetic := len(tempW.Items) - len(w.Items)
+			if trainSize <= synthetic {
+				continue
 			}
-
-			predicted := trainDataset.VectorAutoregression(lagOrder)
-			_, knnResult := trainDataset.KNearestNeighbor(kValue, predicted, withSynth)
-			actual := tempW.Items[j]
-
-			flood := false
-			if knnResult == "Flood" {
-				flood = true
+			stlSplit, err := w.stlSplit(trainSize - synthetic)
+			if err != nil {
+				continue
 			}
+			split.Items = append(append([]Weather{}, tempW.Items[:synthetic]...), stlSplit.Items...)
+		}
 
-			if actual.Flood && flood {
-				confusionMatrix[i-1].TruePositive += 1
-			}
-			if !actual.Flood && flood {
-				confusionMatrix[i-1].FalsePositive += 1
-			}
-			if actual.Flood && !flood {
-				confusionMatrix[i-1].FalseNegative += 1
+		var scores []knnScore
+		index := newKnnIndex(split.Items[:trainSize], options)
+		rolling := split.newRollingVar(lagOrder, ExogenousOptions{})
+		for j := trainSize; j < len(split.Items)-1; j++ {
+			if ctx.Err() != nil {
+				return
 			}
-			if !actual.Flood && !flood {
-				confusionMatrix[i-1].TrueNegative += 1
+			for k := len(index.items); k < j; k++ {
+				index.add(split.Items[k])
 			}
 
-			predictionCount++
+			predicted, err := rolling.Predict(j)
+			if err != nil {
+				continue
+			}
+			_, knnResult := index.KNearestNeighbor(kValue, predicted)
+			scores = append(scores, knnScore{probability: knnResult.Probability, flood: split.Items[j].Flood})
 		}
-		confusionMatrix[i-1].Metrics()
-		confusionMatrix[i-1].FillString()
-		confusionMatrix[i-1].TrainTestStr = fmt.Sprintf("%s - %s", trainPerc, testPerc)
+		matrix := confusionMatrixAt(scores, options.Threshold)
+		matrix.scoreMetrics(scores)
+		matrix.TrainTestStr = fmt.Sprintf("%s - %s", trainPerc, testPerc)
+		confusionMatrix = append(confusionMatrix, matrix)
 	}
 
 	return
@@ -950,10 +621,9 @@ func (w *Weathers) SmoteOversampling(kValue int, nasa NasaData) (oversampledData
 	}
 	fmt.Println("City Average Cosine Similarity: ", getMean(avgCosineSimilarities))
 
-	structToJsonFile(syntheticData, "Synth.json")
-
 	oversampledData.Items = make([]Weather, len(w.Items))
 	copy(oversampledData.Items, w.Items)
+	oversampledData.Diff = w.Diff
 	oversampledData.Oversample.SynthData = syntheticData
 	oversampledData.Oversample.SynthDataCount = len(syntheticData)
 	oversampledData.SynthItems = append(syntheticData, oversampledData.Items...)
@@ -1310,6 +980,10 @@ func (n *ConfusionMatrix) Metrics() {
 	} else {
 		n.F1Score = 2 * (f1Numerator / f1Denominator)
 	}
+
+	n.MatthewsCorrelation = n.matthewsCorrelation()
+	n.BalancedAccuracy = n.balancedAccuracy()
+	n.Kappa = n.cohenKappa()
 }
 
 func (n *ConfusionMatrix) FillString() {
@@ -1321,6 +995,9 @@ func (n *ConfusionMatrix) FillString() {
 	n.PrecisionStr = strconv.FormatFloat(n.Precision, 'f', 4, 64)
 	n.RecallStr = strconv.FormatFloat(n.Recall, 'f', 4, 64)
 	n.F1ScoreStr = strconv.FormatFloat(n.F1Score, 'f', 4, 64)
+	n.MatthewsCorrelationStr = strconv.FormatFloat(n.MatthewsCorrelation, 'f', 4, 64)
+	n.BalancedAccuracyStr = strconv.FormatFloat(n.BalancedAccuracy, 'f', 4, 64)
+	n.KappaStr = strconv.FormatFloat(n.Kappa, 'f', 4, 64)
 }
 
 func (s *Statistics) FillStatistics(startDate, endDate time.Time, city string) {
@@ -1419,49 +1096,6 @@ func (s *Statistics) FillMap() {
 	}
 }
 
-func adfCriticalValue(dataLength, degreeOfSignificance int) (criticalValue float64) {
-	coefficients := map[int][]float64{
-		1:  {-3.43035, -6.5393, -16.786, -79.433},
-		5:  {-2.86154, -2.8903, -4.234, -40.040},
-		10: {-2.56677, -1.5384, -2.809, -31.223},
-	}
-
-	chosenCoefficients := coefficients[degreeOfSignificance]
-	criticalValue = chosenCoefficients[0] + chosenCoefficients[1]/float64(dataLength) + chosenCoefficients[2]/float64(dataLength) + chosenCoefficients[3]/float64(dataLength)
-	return
-}
-
-func adfTest(input []float64) (isStationary bool, criticalValue float64, gamma float64, err error) {
-	criticalValue = adfCriticalValue(len(input), 5)
-	var responseVectorAsSlice, designMatrixAsSlice []float64
-	for i := 0; i < len(input)-2; i++ {
-		responseVectorAsSlice = append(responseVectorAsSlice, input[i+2]-input[i+1])
-		designMatrixAsSlice = append(designMatrixAsSlice, 1)
-		designMatrixAsSlice = append(designMatrixAsSlice, input[i+1])
-		designMatrixAsSlice = append(designMatrixAsSlice, input[i+1]-input[i])
-	}
-	designMatrixRows, designMatrixCols := len(input)-2, 3
-
-	designMatrix := mat.NewDense(designMatrixRows, designMatrixCols, designMatrixAsSlice)
-	responseVector := mat.NewDense(designMatrixRows, 1, responseVectorAsSlice)
-	transposedDesignMatrix := designMatrix.T()
-	xtxMatrix := mat.NewDense(designMatrixCols, designMatrixCols, nil)
-	xtxMatrix.Mul(transposedDesignMatrix, designMatrix)
-	xtyMatrix := mat.NewDense(3, 1, nil)
-	xtyMatrix.Mul(transposedDesignMatrix, responseVector)
-
-	var inverseXtxMatrix mat.Dense
-	err = inverseXtxMatrix.Inverse(xtxMatrix)
-	if err != nil {
-		return false, 0.0, 0.0, err
-	}
-
-	olsResult := mat.NewDense(3, 1, nil)
-	olsResult.Mul(&inverseXtxMatrix, xtyMatrix)
-	olsData := olsResult.RawMatrix().Data
-	return criticalValue > olsData[1], criticalValue, olsData[1], nil
-}
-
 func difference(input []float64) (output []float64) {
 	if len(input) < 2 {
 		return []float64{}
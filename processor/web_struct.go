@@ -14,6 +14,125 @@ type IndexData struct {
 	Timestamp  int64
 }
 
+type ApiResponse struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+type PredictionRequest struct {
+	City               string   `json:"city"`
+	StartDate          string   `json:"start_date"`
+	EndDate            string   `json:"end_date"`
+	KValue             int      `json:"k_value"`
+	LagOrder           int      `json:"lag_order"`
+	LagSelection       string   `json:"lag_selection"`
+	MaxLagOrder        int      `json:"max_lag_order"`
+	SmoteK             int      `json:"smote_k"`
+	ForecastHorizon    int      `json:"forecast_horizon"`
+	ConfidenceLevel    float64  `json:"confidence_level"`
+	StationarityPolicy string   `json:"stationarity_policy"`
+	AdfRegression      string   `json:"adf_regression"`
+	AdfMaxLag          int      `json:"adf_max_lag"`
+	AdfAutolag         string   `json:"adf_autolag"`
+	Model              string   `json:"model"`
+	JohansenDetOrder   int      `json:"johansen_det_order"`
+	IrfPeriods         int      `json:"irf_periods"`
+	Exogenous          []string `json:"exogenous"`
+	FourierOrder       int      `json:"fourier_order"`
+	SeasonalPeriod     int      `json:"seasonal_period"`
+	ArimaP             *int     `json:"arima_p"`
+	ArimaD             *int     `json:"arima_d"`
+	ArimaQ             *int     `json:"arima_q"`
+	ArimaMethod        string   `json:"arima_method"`
+	Transformation     string   `json:"transformation"`
+	StlPeriod          int      `json:"stl_period"`
+	StlSeasonal        int      `json:"stl_seasonal"`
+	StlRobust          bool     `json:"stl_robust"`
+	StlPeriodic        bool     `json:"stl_periodic"`
+	KnnScaler          string   `json:"knn_scaler"`
+	KnnMetric          string   `json:"knn_metric"`
+	MinkowskiP         float64  `json:"minkowski_p"`
+	KnnWeighting       string   `json:"knn_weighting"`
+	KnnBandwidth       float64  `json:"knn_bandwidth"`
+	KnnThreshold       float64  `json:"knn_threshold"`
+}
+
+type PredictionParams struct {
+	City             string              `json:"city"`
+	StartDate        time.Time           `json:"start_date"`
+	EndDate          time.Time           `json:"end_date"`
+	KValue           int                 `json:"k_value"`
+	LagOrder         int                 `json:"lag_order"`
+	LagSelection     string              `json:"lag_selection"`
+	MaxLagOrder      int                 `json:"max_lag_order"`
+	SmoteK           int                 `json:"smote_k"`
+	ForecastHorizon  int                 `json:"forecast_horizon"`
+	ConfidenceLevel  float64             `json:"confidence_level"`
+	Stationarity     StationarityOptions `json:"stationarity"`
+	Model            string              `json:"model"`
+	JohansenDetOrder int                 `json:"johansen_det_order"`
+	IrfPeriods       int                 `json:"irf_periods"`
+	Exogenous        ExogenousOptions    `json:"exogenous"`
+	Baseline         BaselineOptions     `json:"baseline"`
+	Knn              KnnOptions          `json:"knn"`
+	Latitude         string              `json:"latitude"`
+	Longitude        string              `json:"longitude"`
+}
+
+type PredictionResult struct {
+	Params                                 PredictionParams      `json:"params"`
+	Nasa                                   NasaData              `json:"nasa"`
+	Bnpb                                   BnpbData              `json:"bnpb"`
+	News                                   NewsData              `json:"news"`
+	Weathers                               Weathers              `json:"weathers"`
+	DifferencedWeathers                    Weathers              `json:"differenced_weathers"`
+	SeasonalDecomposition                  SeasonalDecomposition `json:"seasonal_decomposition"`
+	GrangerCausality                       GrangerCausality      `json:"granger_causality"`
+	LagSelection                           LagSelection          `json:"lag_selection"`
+	VectorAutoregressionModel              VarModel              `json:"vector_autoregression_model"`
+	VectorAutoregressionDiagnostics        VarDiagnostics        `json:"vector_autoregression_diagnostics"`
+	Cointegration                          Cointegration         `json:"cointegration"`
+	Model                                  string                `json:"model"`
+	VecmModel                              VecmModel             `json:"vecm_model"`
+	ImpulseResponse                        ImpulseResponse       `json:"impulse_response"`
+	VarianceDecomposition                  VarianceDecomposition `json:"variance_decomposition"`
+	Oversampled                            Weathers              `json:"oversampled"`
+	Prediction                             Weather               `json:"prediction"`
+	OriginalPrediction                     Weather               `json:"original_prediction"`
+	VectorAutoregressionEvaluation         Weathers              `json:"vector_autoregression_evaluation"`
+	OriginalVectorAutoregressionEvaluation Weathers              `json:"original_vector_autoregression_evaluation"`
+	VarxEvaluation                         Weathers              `json:"varx_evaluation"`
+	OriginalVarxEvaluation                 Weathers              `json:"original_varx_evaluation"`
+	BaselineEvaluation                     BaselineEvaluation    `json:"baseline_evaluation"`
+	Neighbors                              Weathers              `json:"neighbors"`
+	KNNResult                              KnnClassification     `json:"knn_result"`
+	OriginalNeighbors                      Weathers              `json:"original_neighbors"`
+	OriginalKNNResult                      KnnClassification     `json:"original_knn_result"`
+	KNNEvaluation                          []ConfusionMatrix     `json:"knn_evaluation"`
+	Forecast                               Forecast              `json:"forecast"`
+	SmoteNeighbors                         Weathers              `json:"smote_neighbors"`
+	SmoteKNNResult                         KnnClassification     `json:"smote_knn_result"`
+	SmoteKNNEvaluation                     []ConfusionMatrix     `json:"smote_knn_evaluation"`
+	Statistics                             Statistics            `json:"statistics"`
+	Duration                               int64                 `json:"duration_ms"`
+	RunID                                  int64                 `json:"run_id,omitempty"`
+}
+
+type Job struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Stage      string            `json:"stage,omitempty"`
+	StageIndex int               `json:"stage_index"`
+	StageCount int               `json:"stage_count"`
+	Progress   float64           `json:"progress"`
+	Params     PredictionParams  `json:"params"`
+	Error      string            `json:"error,omitempty"`
+	Result     *PredictionResult `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+}
+
 type Weather struct {
 	Date                time.Time     `json:"date"`
 	WindSpeed           float64       `json:"wind_speed"`
@@ -42,14 +161,19 @@ type Weathers struct {
 	SynthItems []Weather             `json:"synth_items"`
 	Diff       DifferencedStatistics `json:"diff"`
 	Oversample OversampledStatistics `json:"oversample"`
-	Err        error                 `json:"err"`
+	Err        error                 `json:"-"`
 }
 
 type DifferencedStatistics struct {
-	Step                   int        `json:"step"`
-	CriticalValues         Weather    `json:"critical_values"`
-	Gamma                  Weather    `json:"gamma"`
-	CriticalValuesGammaMap []KeyValue `json:"critical_values_gamma_map"`
+	Transformation string                 `json:"transformation"`
+	Step           int                    `json:"step"`
+	Orders         []int                  `json:"orders"`
+	Policy         string                 `json:"policy"`
+	Stationarity   []VariableStationarity `json:"stationarity"`
+	Anchors        []Weather              `json:"anchors"`
+	Origin         []Weather              `json:"-"`
+	Decomposition  SeasonalDecomposition  `json:"-"`
+	splits         *stlSplits
 }
 
 type OversampledStatistics struct {
@@ -58,19 +182,20 @@ type OversampledStatistics struct {
 }
 
 type Nasa struct {
-	WindSpeed        float64 `json:"wind_speed"`
-	RelHumidity      float64 `json:"rel_humidity"`
-	Precipitation    float64 `json:"precipitation"`
-	TempAverage      float64 `json:"temp_average"`
-	TempMax          float64 `json:"temp_max"`
-	TempMin          float64 `json:"temp_min"`
-	DateStr          string  `json:"date_str"`
-	WindSpeedStr     string  `json:"wind_speed_str"`
-	RelHumidityStr   string  `json:"rel_humidity_str"`
-	PrecipitationStr string  `json:"precipitation_str"`
-	TempAverageStr   string  `json:"temp_average_str"`
-	TempMaxStr       string  `json:"temp_max_str"`
-	TempMinStr       string  `json:"temp_min_str"`
+	Date             time.Time `json:"date"`
+	WindSpeed        float64   `json:"wind_speed"`
+	RelHumidity      float64   `json:"rel_humidity"`
+	Precipitation    float64   `json:"precipitation"`
+	TempAverage      float64   `json:"temp_average"`
+	TempMax          float64   `json:"temp_max"`
+	TempMin          float64   `json:"temp_min"`
+	DateStr          string    `json:"date_str"`
+	WindSpeedStr     string    `json:"wind_speed_str"`
+	RelHumidityStr   string    `json:"rel_humidity_str"`
+	PrecipitationStr string    `json:"precipitation_str"`
+	TempAverageStr   string    `json:"temp_average_str"`
+	TempMaxStr       string    `json:"temp_max_str"`
+	TempMinStr       string    `json:"temp_min_str"`
 }
 
 type NasaData struct {
@@ -123,7 +248,7 @@ type Statistics struct {
 	DifferencedWeathersMap []KeyValue          `json:"differenced_weathers_map"`
 	Smote                  WeatherStatistic    `json:"smote"`
 	SmoteMap               []KeyValue          `json:"smote_map"`
-	Ref                    StatisticsReference `json:"ref"`
+	Ref                    StatisticsReference `json:"-"`
 }
 
 type StatisticsReference struct {
@@ -156,26 +281,378 @@ type WeatherStatistic struct {
 }
 
 type ConfusionMatrix struct {
-	TruePositive     int     `json:"true_positive"`
-	TrueNegative     int     `json:"true_negative"`
-	FalsePositive    int     `json:"false_positive"`
-	FalseNegative    int     `json:"false_negative"`
-	Accuracy         float64 `json:"accuracy"`
-	Precision        float64 `json:"precision"`
-	Recall           float64 `json:"recall"`
-	F1Score          float64 `json:"f1_score"`
-	TrainTestStr     string  `json:"train_test_str"`
-	TruePositiveStr  string  `json:"true_positive_str"`
-	TrueNegativeStr  string  `json:"true_negative_str"`
-	FalsePositiveStr string  `json:"false_positive_str"`
-	FalseNegativeStr string  `json:"false_negative_str"`
-	AccuracyStr      string  `json:"accuracy_str"`
-	PrecisionStr     string  `json:"precision_str"`
-	RecallStr        string  `json:"recall_str"`
-	F1ScoreStr       string  `json:"f1_score_str"`
+	Threshold              float64                `json:"threshold"`
+	TruePositive           int                    `json:"true_positive"`
+	TrueNegative           int                    `json:"true_negative"`
+	FalsePositive          int                    `json:"false_positive"`
+	FalseNegative          int                    `json:"false_negative"`
+	Accuracy               float64                `json:"accuracy"`
+	Precision              float64                `json:"precision"`
+	Recall                 float64                `json:"recall"`
+	F1Score                float64                `json:"f1_score"`
+	TrainTestStr           string                 `json:"train_test_str"`
+	TruePositiveStr        string                 `json:"true_positive_str"`
+	TrueNegativeStr        string                 `json:"true_negative_str"`
+	FalsePositiveStr       string                 `json:"false_positive_str"`
+	FalseNegativeStr       string                 `json:"false_negative_str"`
+	AccuracyStr            string                 `json:"accuracy_str"`
+	PrecisionStr           string                 `json:"precision_str"`
+	RecallStr              string                 `json:"recall_str"`
+	F1ScoreStr             string                 `json:"f1_score_str"`
+	MatthewsCorrelation    float64                `json:"matthews_correlation"`
+	BalancedAccuracy       float64                `json:"balanced_accuracy"`
+	Kappa                  float64                `json:"kappa"`
+	Brier                  float64                `json:"brier"`
+	RocAuc                 float64                `json:"roc_auc"`
+	PrAuc                  float64                `json:"pr_auc"`
+	Roc                    []RocPoint             `json:"roc"`
+	PrecisionRecall        []PrecisionRecallPoint `json:"precision_recall"`
+	CurveError             string                 `json:"curve_error,omitempty"`
+	MatthewsCorrelationStr string                 `json:"matthews_correlation_str"`
+	BalancedAccuracyStr    string                 `json:"balanced_accuracy_str"`
+	KappaStr               string                 `json:"kappa_str"`
+	BrierStr               string                 `json:"brier_str"`
+	RocAucStr              string                 `json:"roc_auc_str"`
+	PrAucStr               string                 `json:"pr_auc_str"`
+}
+
+type RocPoint struct {
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	TruePositiveRate  float64 `json:"true_positive_rate"`
+}
+
+type PrecisionRecallPoint struct {
+	Recall    float64 `json:"recall"`
+	Precision float64 `json:"precision"`
 }
 
 type KeyValue struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
+
+type ImportReport struct {
+	Dataset    string        `json:"dataset"`
+	SourceFile string        `json:"source_file"`
+	Rows       int           `json:"rows"`
+	Accepted   int           `json:"accepted"`
+	Inserted   int           `json:"inserted"`
+	Duplicates int           `json:"duplicates"`
+	Rejected   []RejectedRow `json:"rejected"`
+}
+
+type RejectedRow struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+type GrangerCausality struct {
+	MaxLag    int      `json:"max_lag"`
+	Variables []string `json:"variables"`
+	// Pairs[i][j] tests whether Variables[i] Granger-causes Variables[j], the diagonal is left empty.
+	Pairs [][]GrangerCausalityPair `json:"pairs"`
+}
+
+type GrangerCausalityPair struct {
+	Cause         string                `json:"cause"`
+	Effect        string                `json:"effect"`
+	Lags          []GrangerCausalityLag `json:"lags"`
+	MinFPValue    float64               `json:"min_f_p_value"`
+	MinChi2PValue float64               `json:"min_chi2_p_value"`
+	Significant   bool                  `json:"significant"`
+	Error         string                `json:"error,omitempty"`
+	MinFPValueStr string                `json:"min_f_p_value_str"`
+}
+
+type GrangerCausalityLag struct {
+	Lag        int     `json:"lag"`
+	F          float64 `json:"f"`
+	FPValue    float64 `json:"f_p_value"`
+	DfNum      int     `json:"df_num"`
+	DfDenom    int     `json:"df_denom"`
+	Chi2       float64 `json:"chi2"`
+	Chi2PValue float64 `json:"chi2_p_value"`
+}
+
+// VarModel is a least squares VAR fit. Coefficients has a row per equation: the intercept, then every variable at lag 1, lag 2 and so on.
+type VarModel struct {
+	LagOrder     int         `json:"lag_order"`
+	Variables    []string    `json:"variables"`
+	Nobs         int         `json:"nobs"`
+	Coefficients [][]float64 `json:"coefficients"`
+	// Trend multiplies the index of the forecasted row in the fitted data, only the VAR form of a VECM has one.
+	Trend     []float64   `json:"trend,omitempty"`
+	SigmaU    [][]float64 `json:"sigma_u"`
+	Residuals [][]float64 `json:"-"`
+	// Exogenous regressors follow the lags in every coefficient row, in ExogenousNames order.
+	Exogenous      ExogenousOptions `json:"exogenous"`
+	ExogenousNames []string         `json:"exogenous_names,omitempty"`
+}
+
+type ExogenousOptions struct {
+	Regressors   []string `json:"regressors"`
+	FourierOrder int      `json:"fourier_order"`
+}
+
+type LagSelection struct {
+	Criterion string        `json:"criterion"`
+	MaxLag    int           `json:"max_lag"`
+	Selected  int           `json:"selected"`
+	BestAIC   int           `json:"best_aic"`
+	BestBIC   int           `json:"best_bic"`
+	BestHQIC  int           `json:"best_hqic"`
+	BestFPE   int           `json:"best_fpe"`
+	Criteria  []LagCriteria `json:"criteria"`
+}
+
+type LagCriteria struct {
+	Lag     int     `json:"lag"`
+	Nobs    int     `json:"nobs"`
+	AIC     float64 `json:"aic"`
+	BIC     float64 `json:"bic"`
+	HQIC    float64 `json:"hqic"`
+	FPE     float64 `json:"fpe"`
+	AICStr  string  `json:"aic_str"`
+	BICStr  string  `json:"bic_str"`
+	HQICStr string  `json:"hqic_str"`
+	FPEStr  string  `json:"fpe_str"`
+}
+
+type Forecast struct {
+	Horizon         int           `json:"horizon"`
+	ConfidenceLevel float64       `json:"confidence_level"`
+	Days            []ForecastDay `json:"days"`
+	OriginalDays    []ForecastDay `json:"original_days"`
+}
+
+type ForecastDay struct {
+	Step                int               `json:"step"`
+	Prediction          Weather           `json:"prediction"`
+	Lower               Weather           `json:"lower"`
+	Upper               Weather           `json:"upper"`
+	FloodNeighbors      int               `json:"flood_neighbors"`
+	FloodProbability    float64           `json:"flood_probability"`
+	KNNResult           KnnClassification `json:"knn_result"`
+	FloodProbabilityStr string            `json:"flood_probability_str"`
+}
+
+type VariableStationarity struct {
+	Variable   string             `json:"variable"`
+	Order      int                `json:"order"`
+	Stationary bool               `json:"stationary"`
+	Tests      []StationarityTest `json:"tests"`
+}
+
+type StationarityTest struct {
+	Test           string         `json:"test"`
+	Regression     string         `json:"regression"`
+	Autolag        string         `json:"autolag"`
+	Statistic      float64        `json:"statistic"`
+	PValue         float64        `json:"p_value"`
+	UsedLag        int            `json:"used_lag"`
+	Nobs           int            `json:"nobs"`
+	CriticalValues CriticalValues `json:"critical_values"`
+	Stationary     bool           `json:"stationary"`
+	StatisticStr   string         `json:"statistic_str"`
+	PValueStr      string         `json:"p_value_str"`
+}
+
+type CriticalValues struct {
+	OnePercent     float64 `json:"one_percent"`
+	FivePercent    float64 `json:"five_percent"`
+	TenPercent     float64 `json:"ten_percent"`
+	OnePercentStr  string  `json:"one_percent_str"`
+	FivePercentStr string  `json:"five_percent_str"`
+	TenPercentStr  string  `json:"ten_percent_str"`
+}
+
+type Cointegration struct {
+	DetOrder     int                 `json:"det_order"`
+	LagDiff      int                 `json:"lag_diff"`
+	Nobs         int                 `json:"nobs"`
+	Variables    []string            `json:"variables"`
+	Eigenvalues  []float64           `json:"eigenvalues"`
+	Ranks        []CointegrationRank `json:"ranks"`
+	TraceRank    int                 `json:"trace_rank"`
+	MaxEigenRank int                 `json:"max_eigen_rank"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// CointegrationRank tests the null hypothesis of a cointegration rank of at most Rank.
+type CointegrationRank struct {
+	Rank                   int            `json:"rank"`
+	Eigenvalue             float64        `json:"eigenvalue"`
+	Trace                  float64        `json:"trace"`
+	TraceCriticalValues    CriticalValues `json:"trace_critical_values"`
+	TraceRejected          bool           `json:"trace_rejected"`
+	MaxEigen               float64        `json:"max_eigen"`
+	MaxEigenCriticalValues CriticalValues `json:"max_eigen_critical_values"`
+	MaxEigenRejected       bool           `json:"max_eigen_rejected"`
+	EigenvalueStr          string         `json:"eigenvalue_str"`
+	TraceStr               string         `json:"trace_str"`
+	MaxEigenStr            string         `json:"max_eigen_str"`
+}
+
+type VecmModel struct {
+	DetOrder  int         `json:"det_order"`
+	Rank      int         `json:"rank"`
+	LagDiff   int         `json:"lag_diff"`
+	Variables []string    `json:"variables"`
+	Nobs      int         `json:"nobs"`
+	Constant  []float64   `json:"constant"`
+	Trend     []float64   `json:"trend,omitempty"`
+	Alpha     [][]float64 `json:"alpha"`
+	Beta      [][]float64 `json:"beta"`
+	// Gamma rows are [Gamma_1 row, Gamma_2 row, ...] per equation.
+	Gamma  [][]float64 `json:"gamma"`
+	SigmaU [][]float64 `json:"sigma_u"`
+}
+
+type ImpulseResponse struct {
+	Periods   int      `json:"periods"`
+	Variables []string `json:"variables"`
+	// Responses[h][i][j] is the response of Variables[i] h days after a one standard deviation shock to Variables[j].
+	Responses [][][]float64 `json:"responses"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type VarianceDecomposition struct {
+	Periods   int      `json:"periods"`
+	Variables []string `json:"variables"`
+	// Decomposition[h][i][j] is the share of the h+1 step forecast error variance of Variables[i] due to Variables[j].
+	Decomposition [][][]float64 `json:"decomposition"`
+	Error         string        `json:"error,omitempty"`
+	FinalStr      [][]string    `json:"final_str"`
+}
+
+type VarDiagnostics struct {
+	Portmanteau PortmanteauTest `json:"portmanteau"`
+	Normality   NormalityTest   `json:"normality"`
+	Stability   StabilityCheck  `json:"stability"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type PortmanteauTest struct {
+	Lags                 int     `json:"lags"`
+	Df                   int     `json:"df"`
+	Statistic            float64 `json:"statistic"`
+	PValue               float64 `json:"p_value"`
+	AdjustedStatistic    float64 `json:"adjusted_statistic"`
+	AdjustedPValue       float64 `json:"adjusted_p_value"`
+	White                bool    `json:"white"`
+	StatisticStr         string  `json:"statistic_str"`
+	PValueStr            string  `json:"p_value_str"`
+	AdjustedStatisticStr string  `json:"adjusted_statistic_str"`
+	AdjustedPValueStr    string  `json:"adjusted_p_value_str"`
+}
+
+type NormalityTest struct {
+	Df int `json:"df"`
+	// SkewnessStatistic and KurtosisStatistic are the two chi-square components of Statistic, K degrees of freedom each.
+	SkewnessStatistic float64             `json:"skewness_statistic"`
+	KurtosisStatistic float64             `json:"kurtosis_statistic"`
+	Statistic         float64             `json:"statistic"`
+	PValue            float64             `json:"p_value"`
+	Normal            bool                `json:"normal"`
+	Variables         []VariableNormality `json:"variables"`
+	StatisticStr      string              `json:"statistic_str"`
+	PValueStr         string              `json:"p_value_str"`
+}
+
+type VariableNormality struct {
+	Variable      string  `json:"variable"`
+	Skewness      float64 `json:"skewness"`
+	Kurtosis      float64 `json:"kurtosis"`
+	JarqueBera    float64 `json:"jarque_bera"`
+	PValue        float64 `json:"p_value"`
+	Normal        bool    `json:"normal"`
+	SkewnessStr   string  `json:"skewness_str"`
+	KurtosisStr   string  `json:"kurtosis_str"`
+	JarqueBeraStr string  `json:"jarque_bera_str"`
+	PValueStr     string  `json:"p_value_str"`
+}
+
+type StabilityCheck struct {
+	Moduli        []float64 `json:"moduli"`
+	MaxModulus    float64   `json:"max_modulus"`
+	Stable        bool      `json:"stable"`
+	MaxModulusStr string    `json:"max_modulus_str"`
+}
+
+// KnnOptions chooses how the KNN classifier scales the variables and measures the distance between days.
+type KnnOptions struct {
+	Scaler     string  `json:"scaler"`
+	Metric     string  `json:"metric"`
+	MinkowskiP float64 `json:"minkowski_p"`
+	Weighting  string  `json:"weighting"`
+	Bandwidth  float64 `json:"bandwidth"`
+	Threshold  float64 `json:"threshold"`
+}
+
+// KnnClassification is the outcome of a KNN vote, Flood when the weighted share of flood neighbors is above the
+// threshold.
+type KnnClassification struct {
+	Flood          bool    `json:"flood"`
+	Probability    float64 `json:"probability"`
+	Threshold      float64 `json:"threshold"`
+	Weighting      string  `json:"weighting"`
+	FloodNeighbors int     `json:"flood_neighbors"`
+	Label          string  `json:"label"`
+	ProbabilityStr string  `json:"probability_str"`
+}
+
+type BaselineOptions struct {
+	SeasonalPeriod int    `json:"seasonal_period"`
+	ArimaP         int    `json:"arima_p"`
+	ArimaD         int    `json:"arima_d"`
+	ArimaQ         int    `json:"arima_q"`
+	ArimaMethod    string `json:"arima_method"`
+}
+
+type BaselineEvaluation struct {
+	Options     BaselineOptions           `json:"options"`
+	ArimaOrders []ArimaOrder              `json:"arima_orders"`
+	Models      []BaselineModelEvaluation `json:"models"`
+	Rankings    []BaselineRanking         `json:"rankings"`
+}
+
+type ArimaOrder struct {
+	Variable string `json:"variable"`
+	P        int    `json:"p"`
+	D        int    `json:"d"`
+	Q        int    `json:"q"`
+}
+
+type BaselineModelEvaluation struct {
+	Model string    `json:"model"`
+	Items []Weather `json:"items"`
+	Mean  Weather   `json:"mean"`
+	Error string    `json:"error,omitempty"`
+}
+
+type BaselineRanking struct {
+	Variable string      `json:"variable"`
+	Models   []ModelRank `json:"models"`
+}
+
+type ModelRank struct {
+	Rank     int     `json:"rank"`
+	Model    string  `json:"model"`
+	Nrmse    float64 `json:"nrmse"`
+	NrmseStr string  `json:"nrmse_str"`
+}
+
+type SeasonalDecomposition struct {
+	Options             StlOptions  `json:"options"`
+	Variables           []string    `json:"variables"`
+	Dates               []time.Time `json:"dates"`
+	Trend               [][]float64 `json:"trend"`
+	Seasonal            [][]float64 `json:"seasonal"`
+	Residual            [][]float64 `json:"residual"`
+	TrendStrength       []float64   `json:"trend_strength"`
+	SeasonalStrength    []float64   `json:"seasonal_strength"`
+	SeasonalOutlook     []Weather   `json:"seasonal_outlook"`
+	Error               string      `json:"error,omitempty"`
+	TrendStrengthStr    []string    `json:"trend_strength_str"`
+	SeasonalStrengthStr []string    `json:"seasonal_strength_str"`
+}
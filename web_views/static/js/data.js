@@ -1,22 +1,110 @@
+let impulseResponse, impulseResponseChart, varianceDecomposition, varianceDecompositionChart
+let seasonalDecomposition, seasonalDecompositionChart
+
 function initializeChart(jsData) {
     if (!window.Chart) {
         console.warn("Chart.js not loaded.");
         return;
     }
 
-    const nasaLabels = jsData.NasaHeaders.slice(1)
-    const nasaValues = jsData.NasaValues
-    const nrmseLabels = jsData.NRMSEEvaluationHeaders.slice(1)
-    const nrmseValues = jsData.NRMSEEvaluationValues
+    // Create the chart, mainv2 only sends the forecast
+    if (jsData.NasaHeaders && document.getElementById('nasaTable')) {
+        const nasaLabels = jsData.NasaHeaders.slice(1)
+        const nasaValues = jsData.NasaValues
+        new Chart(
+            document.getElementById('nasaTable'),
+            getNasaConfig(nasaLabels, nasaValues)
+        )
+    }
+    if (jsData.NRMSEEvaluationHeaders && document.getElementById('nrmseTable')) {
+        const nrmseLabels = jsData.NRMSEEvaluationHeaders.slice(1)
+        const nrmseValues = jsData.NRMSEEvaluationValues
+        new Chart(
+            document.getElementById('nrmseTable'),
+            getNRMSEConfig(nrmseLabels, nrmseValues)
+        )
+    }
+    if (jsData.Forecast && (jsData.Forecast.original_days || jsData.Forecast.days) && document.getElementById('forecastRiskChart')) {
+        // Prefer original units, a series that needed no differencing only has days and a VECM only original_days
+        const days = jsData.Forecast.original_days || jsData.Forecast.days
+        new Chart(
+            document.getElementById('forecastRiskChart'),
+            getForecastRiskConfig(days)
+        )
+        new Chart(
+            document.getElementById('forecastPrecipitationChart'),
+            getForecastPrecipitationConfig(days, jsData.Forecast.confidence_level)
+        )
+    }
+    if (jsData.ImpulseResponse && jsData.ImpulseResponse.responses && document.getElementById('impulseResponseChart')) {
+        impulseResponse = jsData.ImpulseResponse
+        // RH2M, the humidity shock
+        updateImpulseResponseChart(1)
+    }
+    if (jsData.VarianceDecomposition && jsData.VarianceDecomposition.decomposition && document.getElementById('varianceDecompositionChart')) {
+        varianceDecomposition = jsData.VarianceDecomposition
+        // PRECTOTCORR
+        updateVarianceDecompositionChart(2)
+    }
+    if (jsData.SeasonalDecomposition && jsData.SeasonalDecomposition.trend && document.getElementById('seasonalDecompositionChart')) {
+        seasonalDecomposition = jsData.SeasonalDecomposition
+        // PRECTOTCORR
+        updateSeasonalDecompositionChart(2)
+    }
+    const evaluations = [
+        {id: 'knnEval', evaluation: jsData.KNNEvaluation, title: 'KNN'},
+        {id: 'smoteKnnEval', evaluation: jsData.SmoteKNNEvaluation, title: 'KNN After Oversampling'}
+    ]
+    evaluations.forEach(({id, evaluation, title}) => {
+        if (evaluation && document.getElementById(`${id}RocChart`)) {
+            new Chart(
+                document.getElementById(`${id}RocChart`),
+                getRocConfig(evaluation, title)
+            )
+            new Chart(
+                document.getElementById(`${id}PrecisionRecallChart`),
+                getPrecisionRecallConfig(evaluation, title)
+            )
+        }
+    })
+}
 
-    // Create the chart
-    new Chart(
-        document.getElementById('nasaTable'),
-        getNasaConfig(nasaLabels, nasaValues)
+function updateImpulseResponseChart(impulse) {
+    if (!impulseResponse) {
+        return
+    }
+    if (impulseResponseChart) {
+        impulseResponseChart.destroy()
+    }
+    impulseResponseChart = new Chart(
+        document.getElementById('impulseResponseChart'),
+        getImpulseResponseConfig(impulseResponse, impulse)
     )
-    new Chart(
-        document.getElementById('nrmseTable'),
-        getNRMSEConfig(nrmseLabels, nrmseValues)
+}
+
+function updateVarianceDecompositionChart(variable) {
+    if (!varianceDecomposition) {
+        return
+    }
+    if (varianceDecompositionChart) {
+        varianceDecompositionChart.destroy()
+    }
+    varianceDecompositionChart = new Chart(
+        document.getElementById('varianceDecompositionChart'),
+        getVarianceDecompositionConfig(varianceDecomposition, variable)
+    )
+}
+
+function updateSeasonalDecompositionChart(variable) {
+    if (!seasonalDecomposition) {
+        return
+    }
+    if (seasonalDecompositionChart) {
+        seasonalDecompositionChart.destroy()
+    }
+    seasonalDecompositionChart = new Chart(
+        document.getElementById('seasonalDecompositionChart'),
+        getSeasonalDecompositionConfig(seasonalDecomposition, variable)
     )
 }
 
@@ -124,3 +212,395 @@ function getNRMSEConfig(labels, values) {
 
     return config
 }
+
+function getForecastRiskConfig(days) {
+    const data = {
+        labels: days.map(day => day.prediction.date_str),
+        datasets: [{
+            label: 'Flood Risk (%)',
+            data: days.map(day => day.flood_probability * 100),
+            backgroundColor: days.map(day => day.knn_result.flood ? 'hsl(0, 50%, 60%)' : 'hsl(150, 50%, 60%)'),
+        }]
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'bar',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: 'KNN Flood Risk for Each Forecasted Day'
+                }
+            },
+            scales: {
+                x: {
+                    title: {
+                        display: true,
+                        text: 'Date'
+                    }
+                },
+                y: {
+                    min: 0,
+                    max: 100,
+                    title: {
+                        display: true,
+                        text: 'Flood Risk (%)'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getForecastPrecipitationConfig(days, confidenceLevel) {
+    const data = {
+        labels: days.map(day => day.prediction.date_str),
+        datasets: [
+            {
+                label: `Upper (${confidenceLevel * 100}%)`,
+                data: days.map(day => day.upper.precipitation),
+                borderColor: 'rgba(0, 0, 0, 0)',
+                backgroundColor: 'hsla(210, 50%, 60%, 0.3)',
+                fill: '+1',
+                pointRadius: 0
+            },
+            {
+                label: `Lower (${confidenceLevel * 100}%)`,
+                data: days.map(day => day.lower.precipitation),
+                borderColor: 'rgba(0, 0, 0, 0)',
+                backgroundColor: 'rgba(0, 0, 0, 0)',
+                fill: false,
+                pointRadius: 0
+            },
+            {
+                label: 'PRECTOTCORR',
+                data: days.map(day => day.prediction.precipitation),
+                borderColor: 'hsl(210, 50%, 40%)',
+                backgroundColor: 'rgba(0, 0, 0, 0)',
+                fill: false,
+                tension: 0.1
+            }
+        ]
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'line',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: 'Forecasted Precipitation with Confidence Interval'
+                }
+            },
+            scales: {
+                x: {
+                    title: {
+                        display: true,
+                        text: 'Date'
+                    }
+                },
+                y: {
+                    title: {
+                        display: true,
+                        text: 'PRECTOTCORR'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getImpulseResponseConfig(irf, impulse) {
+    const variables = irf.variables
+    const data = {
+        labels: irf.responses.map((_, day) => day),
+        datasets: variables.map((variable, index) => {
+            return {
+                label: variable,
+                data: irf.responses.map(response => response[index][impulse]),
+                borderColor: `hsl(${index * 360 / variables.length}, 50%, 40%)`,
+                backgroundColor: `rgba(0, 0, 0, 0)`,
+                fill: false,
+                tension: 0.1
+            };
+        })
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'line',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: `Responses to a ${variables[impulse]} Shock`
+                }
+            },
+            scales: {
+                x: {
+                    title: {
+                        display: true,
+                        text: 'Days after the Shock'
+                    }
+                },
+                y: {
+                    title: {
+                        display: true,
+                        text: 'Response'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getVarianceDecompositionConfig(fevd, variable) {
+    const variables = fevd.variables
+    const data = {
+        labels: fevd.decomposition.map((_, step) => step + 1),
+        datasets: variables.map((shock, index) => {
+            return {
+                label: shock,
+                data: fevd.decomposition.map(shares => shares[variable][index] * 100),
+                backgroundColor: `hsl(${index * 360 / variables.length}, 50%, 60%)`,
+            };
+        })
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'bar',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: `Forecast Error Variance Decomposition of ${variables[variable]}`
+                }
+            },
+            scales: {
+                x: {
+                    stacked: true,
+                    title: {
+                        display: true,
+                        text: 'Days Ahead'
+                    }
+                },
+                y: {
+                    stacked: true,
+                    min: 0,
+                    max: 100,
+                    title: {
+                        display: true,
+                        text: 'Share (%)'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getSeasonalDecompositionConfig(stl, variable) {
+    const components = [
+        {label: 'Trend', values: stl.trend[variable]},
+        {label: 'Seasonal', values: stl.seasonal[variable]},
+        {label: 'Residual', values: stl.residual[variable]}
+    ]
+    const data = {
+        labels: stl.dates.map(date => date.slice(0, 10)),
+        datasets: components.map((component, index) => {
+            return {
+                label: component.label,
+                data: component.values,
+                borderColor: `hsl(${index * 120}, 50%, 40%)`,
+                backgroundColor: `rgba(0, 0, 0, 0)`,
+                borderWidth: 1,
+                pointRadius: 0,
+                fill: false
+            };
+        })
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'line',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: `STL Decomposition of ${stl.variables[variable]}`
+                }
+            },
+            scales: {
+                x: {
+                    title: {
+                        display: true,
+                        text: 'Date'
+                    }
+                },
+                y: {
+                    title: {
+                        display: true,
+                        text: 'Value'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getRocConfig(evaluation, title) {
+    // Splits with a single class have no curve
+    const splits = evaluation.filter(split => split.roc)
+    const data = {
+        datasets: splits.map((split, index) => {
+            return {
+                label: `${split.train_test_str} (AUC ${split.roc_auc_str})`,
+                data: split.roc.map(point => ({x: point.false_positive_rate, y: point.true_positive_rate})),
+                borderColor: `hsl(${index * 360 / splits.length}, 50%, 50%)`,
+                backgroundColor: `hsl(${index * 360 / splits.length}, 50%, 50%)`,
+                showLine: true,
+                pointRadius: 2,
+                fill: false
+            };
+        }).concat([{
+            label: 'Chance',
+            data: [{x: 0, y: 0}, {x: 1, y: 1}],
+            borderColor: 'hsl(0, 0%, 60%)',
+            borderDash: [4, 4],
+            showLine: true,
+            pointRadius: 0,
+            fill: false
+        }])
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'scatter',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: `ROC Curve of ${title}`
+                }
+            },
+            scales: {
+                x: {
+                    min: 0,
+                    max: 1,
+                    title: {
+                        display: true,
+                        text: 'False Positive Rate'
+                    }
+                },
+                y: {
+                    min: 0,
+                    max: 1,
+                    title: {
+                        display: true,
+                        text: 'True Positive Rate'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}
+
+function getPrecisionRecallConfig(evaluation, title) {
+    const splits = evaluation.filter(split => split.precision_recall)
+    const data = {
+        datasets: splits.map((split, index) => {
+            return {
+                label: `${split.train_test_str} (AP ${split.pr_auc_str})`,
+                data: split.precision_recall.map(point => ({x: point.recall, y: point.precision})),
+                borderColor: `hsl(${index * 360 / splits.length}, 50%, 50%)`,
+                backgroundColor: `hsl(${index * 360 / splits.length}, 50%, 50%)`,
+                showLine: true,
+                stepped: 'after',
+                pointRadius: 2,
+                fill: false
+            };
+        })
+    };
+
+    // Chart configuration
+    const config = {
+        type: 'scatter',
+        data: data,
+        options: {
+            responsive: true,
+            plugins: {
+                legend: {
+                    position: 'top',
+                },
+                title: {
+                    display: true,
+                    text: `Precision-Recall Curve of ${title}`
+                }
+            },
+            scales: {
+                x: {
+                    min: 0,
+                    max: 1,
+                    title: {
+                        display: true,
+                        text: 'Recall'
+                    }
+                },
+                y: {
+                    min: 0,
+                    max: 1,
+                    title: {
+                        display: true,
+                        text: 'Precision'
+                    }
+                }
+            }
+        }
+    }
+
+    return config
+}